@@ -0,0 +1,384 @@
+package web
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMultipartMemory bytes of multipart form kept in memory by Bind, the rest is stored in temporary files
+const maxMultipartMemory = 32 << 20
+
+// FieldError one field failing to bind or validate
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// BindError fields failing to bind or validate
+type BindError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *BindError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+func (e *BindError) add(field, rule, format string, v ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Rule: rule, Message: fmt.Sprintf(format, v...)})
+}
+
+// Bind decode request into the struct pointed by v, then validate it, response 400 listing
+// every failing field if fail. Body is decoded by Content-Type: json by `json` tags, urlencoded
+// and multipart form by `form` tags (*multipart.FileHeader and []*multipart.FileHeader for files).
+// Fields tagged `query`, `param` and `header` are taken from the query string, path params and
+// headers. See Validate for the `validate` tag.
+func (ctx *Context) Bind(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("web: Bind of non struct pointer %T", v))
+	}
+	err := ctx.bind(rv.Elem())
+	if err == nil {
+		err = Validate(v)
+	}
+	if err != nil {
+		ctx.badRequest(err)
+	}
+	return err
+}
+
+func (ctx *Context) bind(rv reflect.Value) error {
+	errs := &BindError{}
+	if ctx.Request.ContentLength != 0 && ctx.Body != nil && ctx.Body != http.NoBody {
+		mediaType, _, _ := mime.ParseMediaType(ctx.Request.Header.Get("Content-Type"))
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			if err := json.NewDecoder(ctx.Body).Decode(rv.Addr().Interface()); err != nil && err != io.EOF {
+				errs.add("body", "json", "%v", err)
+				return errs
+			}
+		case mediaType == "application/x-www-form-urlencoded":
+			if err := ctx.ParseForm(); err != nil {
+				errs.add("body", "form", "%v", err)
+				return errs
+			}
+			bindValues(rv, "form", ctx.PostForm, errs)
+		case mediaType == "multipart/form-data":
+			if err := ctx.ParseMultipartForm(maxMultipartMemory); err != nil {
+				errs.add("body", "form", "%v", err)
+				return errs
+			}
+			bindValues(rv, "form", ctx.MultipartForm.Value, errs)
+			bindFiles(rv, ctx.MultipartForm.File, errs)
+		}
+	}
+	bindValues(rv, "query", ctx.URL.Query(), errs)
+	params := make(map[string][]string, len(ctx.params))
+	for k, v := range ctx.params {
+		params[k] = []string{v}
+	}
+	bindValues(rv, "param", params, errs)
+	bindValues(rv, "header", ctx.Request.Header, errs)
+	if len(errs.Errors) != 0 {
+		return errs
+	}
+	return nil
+}
+
+func (ctx *Context) badRequest(err error) {
+	var data interface{}
+	if bindErr, ok := err.(*BindError); ok {
+		data = bindErr
+	}
+	ctx.JSONStatus(http.StatusBadRequest, data, http.StatusBadRequest, err)
+}
+
+var fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+
+// eachField call f with every exported field of struct rv, including those of embedded structs
+func eachField(rv reflect.Value, f func(field reflect.StructField, value reflect.Value)) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field, value := rt.Field(i), rv.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			eachField(value, f)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		f(field, value)
+	}
+}
+
+func bindValues(rv reflect.Value, tag string, values map[string][]string, errs *BindError) {
+	if len(values) == 0 {
+		return
+	}
+	eachField(rv, func(field reflect.StructField, value reflect.Value) {
+		name := tagName(field, tag)
+		if name == "" {
+			return
+		}
+		if tag == "header" {
+			name = http.CanonicalHeaderKey(name)
+		}
+		vs, ok := values[name]
+		if !ok || len(vs) == 0 || value.Type() == fileHeaderType {
+			return
+		}
+		if err := setValue(value, vs); err != nil {
+			errs.add(name, "type", "%v", err)
+		}
+	})
+}
+
+func bindFiles(rv reflect.Value, files map[string][]*multipart.FileHeader, errs *BindError) {
+	eachField(rv, func(field reflect.StructField, value reflect.Value) {
+		name := tagName(field, "form")
+		fhs := files[name]
+		if name == "" || len(fhs) == 0 {
+			return
+		}
+		switch {
+		case value.Type() == fileHeaderType:
+			value.Set(reflect.ValueOf(fhs[0]))
+		case value.Kind() == reflect.Slice && value.Type().Elem() == fileHeaderType:
+			value.Set(reflect.ValueOf(fhs))
+		}
+	})
+}
+
+// tagName return the name of field in tag, empty if the field is not tagged or tagged "-"
+func tagName(field reflect.StructField, tag string) string {
+	name := strings.Split(field.Tag.Get(tag), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setValue set value by strings, support basic types, pointers, slices, time.Duration and encoding.TextUnmarshaler
+func setValue(value reflect.Value, vs []string) error {
+	if value.Kind() != reflect.Ptr && value.CanAddr() && value.Addr().Type().Implements(textUnmarshalerType) {
+		return value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(vs[0]))
+	}
+	switch value.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(value.Type().Elem())
+		if err := setValue(elem.Elem(), vs); err != nil {
+			return err
+		}
+		value.Set(elem)
+		return nil
+	case reflect.Slice:
+		slice := reflect.MakeSlice(value.Type(), len(vs), len(vs))
+		for i, s := range vs {
+			if err := setValue(slice.Index(i), []string{s}); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+		return nil
+	}
+
+	s := vs[0]
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", s)
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", s)
+			}
+			value.SetInt(int64(d))
+			return nil
+		}
+		i, err := strconv.ParseInt(s, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", s)
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		value.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+// Validate check the struct pointed by v by `validate` tags, rules separated by comma:
+//
+//	required      value must not be zero
+//	min=N, max=N  bounds of numbers, or of length for strings, slices and maps
+//	enum=a|b|c    value must be one of
+//	regex=EXPR    string must match, must be the last rule as EXPR may contain comma
+//
+// Rules other than required are skipped for zero values. Nested structs are validated too.
+// It returns *BindError listing every failing field.
+func Validate(v interface{}) error {
+	errs := &BindError{}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() == reflect.Struct {
+		validateStruct(rv, "", errs)
+	}
+	if len(errs.Errors) != 0 {
+		return errs
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func validateStruct(rv reflect.Value, prefix string, errs *BindError) {
+	eachField(rv, func(field reflect.StructField, value reflect.Value) {
+		name := prefix + fieldName(field)
+		if rules := field.Tag.Get("validate"); rules != "" && rules != "-" {
+			validateField(value, name, rules, errs)
+		}
+		nested := reflect.Indirect(value)
+		if nested.Kind() == reflect.Struct && nested.Type() != timeType {
+			validateStruct(nested, name+".", errs)
+		}
+	})
+}
+
+// fieldName return name of field in error, by the first tag found
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "query", "param", "header"} {
+		if name := tagName(field, tag); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func validateField(value reflect.Value, name, rules string, errs *BindError) {
+	for rules != "" {
+		var rule string
+		if strings.HasPrefix(rules, "regex=") {
+			rule, rules = rules, ""
+		} else if i := strings.IndexByte(rules, ','); i >= 0 {
+			rule, rules = rules[:i], rules[i+1:]
+		} else {
+			rule, rules = rules, ""
+		}
+		key, arg := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			key, arg = rule[:i], rule[i+1:]
+		}
+
+		if key == "required" {
+			if value.IsZero() {
+				errs.add(name, key, "is required")
+				return
+			}
+			continue
+		}
+		if value.IsZero() {
+			return
+		}
+		v := reflect.Indirect(value)
+		switch key {
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				panic(fmt.Sprintf("web: invalid validate rule %q of %s", rule, name))
+			}
+			n, unit, ok := measure(v)
+			if !ok {
+				panic(fmt.Sprintf("web: validate rule %q unsupported by %s of %s", rule, v.Type(), name))
+			}
+			if key == "min" && n < limit {
+				errs.add(name, key, "must be at least %s%s", arg, unit)
+			}
+			if key == "max" && n > limit {
+				errs.add(name, key, "must be at most %s%s", arg, unit)
+			}
+		case "enum":
+			s := fmt.Sprint(v.Interface())
+			found := false
+			for _, option := range strings.Split(arg, "|") {
+				if s == option {
+					found = true
+					break
+				}
+			}
+			if !found {
+				errs.add(name, key, "must be one of %s", strings.ReplaceAll(arg, "|", ", "))
+			}
+		case "regex":
+			if v.Kind() != reflect.String {
+				panic(fmt.Sprintf("web: validate rule regex unsupported by %s of %s", v.Type(), name))
+			}
+			if !cachedRegexp(arg).MatchString(v.String()) {
+				errs.add(name, key, "must match %s", arg)
+			}
+		default:
+			panic(fmt.Sprintf("web: unknown validate rule %q of %s", rule, name))
+		}
+	}
+}
+
+// measure return the number compared by min/max: value of numbers, length of others
+func measure(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	case reflect.String:
+		return float64(len([]rune(v.String()))), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items", true
+	}
+	return 0, "", false
+}
+
+var regexps sync.Map
+
+func cachedRegexp(expr string) *regexp.Regexp {
+	if re, ok := regexps.Load(expr); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(expr)
+	regexps.Store(expr, re)
+	return re
+}
@@ -0,0 +1,98 @@
+package web_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corex-io/web"
+)
+
+type bindRequest struct {
+	ID      int                   `param:"id" validate:"required,min=1"`
+	Name    string                `json:"name" form:"name" validate:"required,min=2,max=8"`
+	Role    string                `json:"role" form:"role" validate:"enum=admin|user"`
+	Tags    []string              `query:"tag" validate:"max=2"`
+	Page    *uint                 `query:"page"`
+	Timeout time.Duration         `query:"timeout"`
+	Token   string                `header:"x-token" validate:"regex=^[a-z]{2,4}$"`
+	File    *multipart.FileHeader `form:"file"`
+}
+
+func TestBind(t *testing.T) {
+	var got bindRequest
+	app := web.New()
+	app.RouteFunc("/users/:id", func(ctx *web.Context) {
+		got = bindRequest{}
+		if err := ctx.Bind(&got); err != nil {
+			return
+		}
+		ctx.Text([]byte("ok"))
+	})
+
+	multipartBody := &bytes.Buffer{}
+	mw := multipart.NewWriter(multipartBody)
+	_ = mw.WriteField("name", "carol")
+	fw, _ := mw.CreateFormFile("file", "a.txt")
+	fw.Write([]byte("hello"))
+	mw.Close()
+
+	cases := []struct {
+		name, path, contentType, body string
+		code                          int
+		fields                        []string
+	}{
+		{"json", "/users/7?tag=a&tag=b&page=3&timeout=2s", "application/json", `{"name":"alice","role":"admin"}`, http.StatusOK, nil},
+		{"form", "/users/7", "application/x-www-form-urlencoded", url.Values{"name": {"bob"}}.Encode(), http.StatusOK, nil},
+		{"multipart", "/users/7", mw.FormDataContentType(), multipartBody.String(), http.StatusOK, nil},
+		{"invalid", "/users/0?tag=a&tag=b&tag=c&page=x", "application/json", `{"role":"root"}`, http.StatusBadRequest, []string{"page"}},
+		{"validate", "/users/0?tag=a&tag=b&tag=c", "application/json", `{"name":"a","role":"root"}`, http.StatusBadRequest, []string{"id", "name", "role", "tag", "x-token"}},
+		{"malformed", "/users/7", "application/json", `{"name":`, http.StatusBadRequest, []string{"body"}},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		if c.name != "validate" {
+			req.Header.Set("X-Token", "abc")
+		} else {
+			req.Header.Set("X-Token", "ABC")
+		}
+		app.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("%s: expect %d, got %d %s", c.name, c.code, w.Code, w.Body)
+			continue
+		}
+		if c.code != http.StatusOK {
+			var resp struct {
+				Data web.BindError `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Errorf("%s: %v", c.name, err)
+			}
+			var fields []string
+			for _, fe := range resp.Data.Errors {
+				fields = append(fields, fe.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(c.fields, ",") {
+				t.Errorf("%s: expect failing fields %v, got %v", c.name, c.fields, resp.Data.Errors)
+			}
+		}
+	}
+
+	app.ServeHTTP(httptest.NewRecorder(), func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/users/7?tag=a&page=3&timeout=2s", strings.NewReader(`{"name":"alice"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Token", "abc")
+		return req
+	}())
+	if got.ID != 7 || got.Name != "alice" || len(got.Tags) != 1 || got.Page == nil || *got.Page != 3 || got.Timeout != 2*time.Second || got.Token != "abc" {
+		t.Errorf("unexpected bind result %+v", got)
+	}
+}
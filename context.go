@@ -1,15 +1,13 @@
 package web
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
-	"io/ioutil"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -23,22 +21,48 @@ var zeroTime = time.Time{}
 type Context struct {
 	http.ResponseWriter
 	*http.Request
+	web        *Web
+	writer     *responseWriter
+	rw         responseWriter // storage of writer
 	statusCode int
+	params     map[string]string
+	paramBuf   []string // names and values captured by Tree, reused
+	entry      *Entry
+	static     bool // served by Options.StaticPaths
+	handlers   []func(*Context)
+	index      int
+	panic      interface{}
+	values     map[string]interface{}
+	gen        uint32 // odd while idle in the pool
 	Timestamp  time.Time
 	log.Logger
 }
 
 func (ctx *Context) reset() {
-	ctx.ResponseWriter = nil
+	ctx.ResponseWriter = releasedWriter{}
 	ctx.Request = nil
+	ctx.web = nil
+	ctx.writer = nil
+	ctx.rw = responseWriter{}
 	ctx.statusCode = 0
+	for k := range ctx.params {
+		delete(ctx.params, k)
+	}
+	ctx.entry = nil
+	ctx.static = false
+	ctx.handlers = ctx.handlers[:0]
+	ctx.index = 0
+	ctx.panic = nil
+	for k := range ctx.values {
+		delete(ctx.values, k)
+	}
 	ctx.Timestamp = zeroTime
 	ctx.Logger = nil
 }
 
-// IsFinish return handle is closed or not
+// IsFinish return handle is closed or not, by status set or response header sent
 func (ctx *Context) IsFinish() bool {
-	return ctx.statusCode != 0
+	return ctx.statusCode != 0 || ctx.Written()
 }
 
 // GetQuery get query
@@ -127,30 +151,8 @@ func (ctx *Context) Error(statusCode int) {
 	http.Error(ctx.ResponseWriter, http.StatusText(ctx.statusCode), ctx.statusCode)
 }
 
-// Download support download file
-func (ctx *Context) Download(filename string, header map[string]string) {
-	info, err := os.Stat(filename)
-	if err != nil {
-		ctx.Error(toHTTPError(err))
-		return
-	}
-	f, err := os.Open(filename)
-	if err != nil {
-		ctx.Error(toHTTPError(err))
-		return
-	}
-	defer f.Close()
-	ctx.ResponseWriter.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size()))
-	ctx.ResponseWriter.Header().Set("Content-Type", "multipart/form-data")
-	ctx.ResponseWriter.Header().Set("Content-Disposition:", fmt.Sprintf("attachment;filename=%s", filename))
-	for k, v := range header {
-		ctx.ResponseWriter.Header().Set(k, v)
-	}
-	ctx.statusCode = http.StatusOK
-	_, _ = io.Copy(ctx.ResponseWriter, f)
-}
-
-//SaveFile save file to disk
+// SaveFile save file to disk through a temp file, as name replacing an existing file, or as the
+// sanitized client filename with a numeric suffix if taken
 func SaveFile(fh *multipart.FileHeader, path string, name ...string) (string, int64, error) {
 	file, err := fh.Open()
 	if err != nil {
@@ -158,97 +160,70 @@ func SaveFile(fh *multipart.FileHeader, path string, name ...string) (string, in
 	}
 	defer file.Close()
 
-	var filename string
-	if len(name) == 0 {
-		filename = fh.Filename
-	} else {
-		filename = name[0]
+	u := uploader{opts: UploadOptions{Dir: path}}
+	if len(name) != 0 {
+		u.opts.Rename = func(string) string { return name[0] }
+		u.opts.Overwrite = true
 	}
-	filepath := filepath.Join(path, filename)
-	f, err := os.OpenFile(filepath, os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
+	if err := u.save("", fh.Filename, file); err != nil {
 		return "", 0, err
 	}
-	defer f.Close()
-	cnt, err := io.Copy(f, file)
-	return filepath, cnt, err
+	return u.files[0].Path, u.files[0].Size, nil
 }
 
-// RecvFormFile recv form file
+// RecvFormFile save all files of the multipart form into path.
+//
+// Deprecated: use Upload, which limits size and type.
 func (ctx *Context) RecvFormFile(path string) error {
-	var err error
-	const _24K = (1 << 10) * 24 // 24 MB
-	if err := ctx.Request.ParseMultipartForm(_24K); err != nil {
-		return err
-	}
-	for _, fheaders := range ctx.Request.MultipartForm.File {
-		for _, hdr := range fheaders {
-			// open uploaded
-			var infile multipart.File
-			if infile, err = hdr.Open(); err != nil {
-				return err
-			}
-			defer infile.Close()
-			// open destination
-			var outfile *os.File //https://www.jianshu.com/p/3e0c0609d419
-			if outfile, err = os.Create(path + hdr.Filename); err != nil {
-				return err
-			}
-			defer outfile.Close()
-			// 32K buffer copy
-			var written int64
-			if written, err = io.Copy(outfile, infile); err != nil {
-				return err
-			}
-			fmt.Println("uploaded file:" + hdr.Filename + ";length:" + strconv.Itoa(int(written)))
-		}
-	}
-	return nil
+	_, err := ctx.Upload(UploadDir(path))
+	return err
 }
 
-// RecvFile2 recvfile2
+// RecvFile2 save the raw body into path as name replacing an existing file, or as the filename of
+// Content-Disposition if name is empty.
+//
+// Deprecated: use Upload, which limits size and type.
 func (ctx *Context) RecvFile2(name string, path string) error {
-	disposition := ctx.Request.Header.Get("Content-Disposition")
-	filename := strings.Trim(strings.SplitN(disposition, "filename=", 2)[1], `"`)
-
-	tmpfile, err := ioutil.TempFile(".", "tmp")
-	if err != nil {
-		return err
-	}
-	if _, err = io.Copy(tmpfile, ctx.Body); err != nil {
-		return err
+	opts := []UploadOption{UploadDir(path)}
+	if name != "" {
+		opts = append(opts, UploadRename(func(string) string { return name }), UploadOverwrite())
 	}
-	_ = tmpfile.Close()
-	_ = ctx.Body.Close()
-	return os.Rename(tmpfile.Name(), filename)
+	_, err := ctx.Upload(opts...)
+	return err
 }
 
-// RecvFile recv file
+// RecvFile save the file of form field name into path.
+//
+// Deprecated: use Upload, which limits size and type.
 func (ctx *Context) RecvFile(name string, path string) (string, int64, error) {
-	file, head, err := ctx.FormFile(name)
+	files, err := ctx.Upload(UploadDir(path), UploadFields(name), UploadMaxFiles(1))
 	if err != nil {
 		return "", 0, err
 	}
-	defer file.Close()
-	fpath := filepath.Join(path, head.Filename)
-	f, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		return "", 0, err
+	if len(files) == 0 {
+		return "", 0, http.ErrMissingFile
 	}
-	defer f.Close()
-	cnt, err := io.Copy(f, file)
-	return fpath, cnt, err
+	return files[0].Path, files[0].Size, nil
 }
 
-// Render render template no cache
+// Render respond page tpl of the templates loaded by Web.LoadTemplates, or the template file tpl
+// parsed on every call if none loaded
 func (ctx *Context) Render(tpl string, data interface{}) {
-	// path := filepath.Join(ctx.Config.WebPath, tpl)
+	if ctx.web != nil && ctx.web.templates != nil {
+		ctx.HTML(http.StatusOK, tpl, data)
+		return
+	}
 	t, err := template.ParseFiles(tpl)
 	if err != nil {
 		ctx.Error(toHTTPError(err))
 		return
 	}
-	t.Execute(ctx.ResponseWriter, data)
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		ctx.HandleError(err)
+		return
+	}
+	ctx.writeHTML(http.StatusOK, &buf)
 }
 
 // Text return resp with text format
@@ -256,47 +231,53 @@ func (ctx *Context) Text(response []byte) {
 	ctx.ResponseWriter.Write(response)
 }
 
-// JSON json api
+// JSON json api, response 200 with v, business code and err wrapped by the envelope of Web
 func (ctx *Context) JSON(v interface{}, code int, err error) {
-	var msg string
-	if err != nil {
-		msg = err.Error()
-	}
-	var data string
-	switch v.(type) {
-	case []byte:
-		b := v.([]byte)
-		if ok := json.Valid(b); ok {
-			data = string(b)
-		} else {
-			data = fmt.Sprintf(`"%s"`, string(b))
-		}
+	ctx.JSONStatus(http.StatusOK, v, code, err)
+}
 
-	case string:
-		b := v.(string)
-		if ok := json.Valid([]byte(b)); ok {
-			data = b
-		} else {
-			data = fmt.Sprintf(`"%s"`, b)
-		}
+// JSONStatus json api with http status, response 500 if v can't be encoded
+func (ctx *Context) JSONStatus(status int, v interface{}, code int, err error) {
+	ctx.checkReleased()
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(ctx.envelope()(v, code, err)); err != nil {
+		ctx.Logger.Errorf("json encode: %v", err)
+		ctx.Error(http.StatusInternalServerError)
+		return
+	}
+	ctx.SetStatusCode(status)
+	ctx.ResponseWriter.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	ctx.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	ctx.ResponseWriter.WriteHeader(status)
+	_, _ = buf.WriteTo(ctx.ResponseWriter)
+}
 
-	default:
-		b, err := json.Marshal(v)
-		if err != nil {
-			code = 101
-			msg += fmt.Sprintf("\n  json marshal data: %v", err)
-		} else {
-			data = string(b)
-		}
+// JSONStream json api encoding v directly to the response without buffering, for large payloads.
+// The status is sent before encoding, so an encode error can only be logged.
+func (ctx *Context) JSONStream(status int, v interface{}, code int, err error) {
+	ctx.SetStatusCode(status)
+	ctx.ResponseWriter.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	ctx.ResponseWriter.WriteHeader(status)
+	if err := json.NewEncoder(ctx.ResponseWriter).Encode(ctx.envelope()(v, code, err)); err != nil {
+		ctx.Logger.Errorf("json encode: %v", err)
 	}
+}
 
-	if msg == "" {
-		msg = "success"
+func (ctx *Context) envelope() EnvelopeFunc {
+	if ctx.web != nil && ctx.web.Envelope != nil {
+		return ctx.web.Envelope
 	}
-	ctx.SetStatusCode(http.StatusOK)
-	result := fmt.Sprintf(`{"code": %d, "msg": "%s", "data": %s}`, code, msg, data)
-	ctx.ResponseWriter.Header().Set("Content-Type", "application/json;charset=UTF-8")
-	_, _ = ctx.ResponseWriter.Write([]byte(result))
+	return DefaultEnvelope
+}
+
+// SetPanic record the panic value recovered while serving, counted by metrics
+func (ctx *Context) SetPanic(v interface{}) {
+	ctx.panic = v
+}
+
+// Panic return the panic value recovered while serving, nil if none
+func (ctx *Context) Panic() interface{} {
+	return ctx.panic
 }
 
 func (ctx *Context) SetStatusCode(statusCode int) {
@@ -0,0 +1,239 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DownloadOptions options of Context downloads
+type DownloadOptions struct {
+	Name        string            // filename of Content-Disposition, base name of the file by default
+	Inline      bool              // let the browser display it instead of saving
+	ContentType string            // detected from the name and content if empty
+	RateLimit   int64             // bytes per second, unlimited if 0
+	Header      map[string]string // extra response headers
+}
+
+// DownloadOption func
+type DownloadOption func(*DownloadOptions)
+
+// DownloadName set the filename the client saves as
+func DownloadName(name string) DownloadOption {
+	return func(o *DownloadOptions) {
+		o.Name = name
+	}
+}
+
+// DownloadInline let the browser display the content instead of saving
+func DownloadInline() DownloadOption {
+	return func(o *DownloadOptions) {
+		o.Inline = true
+	}
+}
+
+// DownloadContentType set Content-Type instead of detecting it
+func DownloadContentType(contentType string) DownloadOption {
+	return func(o *DownloadOptions) {
+		o.ContentType = contentType
+	}
+}
+
+// DownloadRateLimit limit bytes per second sent of the download
+func DownloadRateLimit(bytesPerSecond int64) DownloadOption {
+	return func(o *DownloadOptions) {
+		o.RateLimit = bytesPerSecond
+	}
+}
+
+// DownloadHeader set an extra response header
+func DownloadHeader(key, value string) DownloadOption {
+	return func(o *DownloadOptions) {
+		if o.Header == nil {
+			o.Header = make(map[string]string)
+		}
+		o.Header[key] = value
+	}
+}
+
+// Download send file as attachment named by its base name, header are extra response headers.
+// See DownloadFile for the supported conditional and range requests.
+func (ctx *Context) Download(filename string, header map[string]string) {
+	opts := make([]DownloadOption, 0, len(header))
+	for k, v := range header {
+		opts = append(opts, DownloadHeader(k, v))
+	}
+	ctx.DownloadFile(filename, opts...)
+}
+
+// DownloadFile send the file at path, see DownloadContent
+func (ctx *Context) DownloadFile(path string, opts ...DownloadOption) {
+	f, err := os.Open(path)
+	if err != nil {
+		ctx.Error(toHTTPError(err))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		ctx.Error(toHTTPError(err))
+		return
+	}
+	if info.IsDir() {
+		ctx.Error(http.StatusNotFound)
+		return
+	}
+	opts = append([]DownloadOption{DownloadName(filepath.Base(path))}, opts...)
+	ctx.downloadContent(info.ModTime(), info.Size(), f, opts...)
+}
+
+// DownloadFS send file name of fsys, like an embed.FS, see DownloadContent
+func (ctx *Context) DownloadFS(fsys fs.FS, name string, opts ...DownloadOption) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		ctx.Error(toHTTPError(err))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		ctx.Error(toHTTPError(err))
+		return
+	}
+	if info.IsDir() {
+		ctx.Error(http.StatusNotFound)
+		return
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			ctx.Error(http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+	opts = append([]DownloadOption{DownloadName(path.Base(name))}, opts...)
+	ctx.downloadContent(info.ModTime(), info.Size(), content, opts...)
+}
+
+// DownloadContent send content with Content-Disposition of RFC 6266 and MIME type detected from
+// the name or content. Range and If-Range requests, including multiple ranges, are answered with
+// 206; If-None-Match and If-Modified-Since by 304, with an ETag from modtime and size if modtime
+// is set.
+func (ctx *Context) DownloadContent(name string, modtime time.Time, content io.ReadSeeker, opts ...DownloadOption) {
+	size, err := content.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = content.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		ctx.Error(http.StatusInternalServerError)
+		return
+	}
+	opts = append([]DownloadOption{DownloadName(name)}, opts...)
+	ctx.downloadContent(modtime, size, content, opts...)
+}
+
+func (ctx *Context) downloadContent(modtime time.Time, size int64, content io.ReadSeeker, opts ...DownloadOption) {
+	var options DownloadOptions
+	for _, o := range opts {
+		o(&options)
+	}
+	header := ctx.ResponseWriter.Header()
+	disposition := "attachment"
+	if options.Inline {
+		disposition = "inline"
+	}
+	header.Set("Content-Disposition", contentDisposition(disposition, options.Name))
+	if options.ContentType != "" {
+		header.Set("Content-Type", options.ContentType)
+	}
+	if !modtime.IsZero() && header.Get("ETag") == "" {
+		header.Set("ETag", fmt.Sprintf(`"%x-%x"`, modtime.UnixNano(), size))
+	}
+	for k, v := range options.Header {
+		header.Set(k, v)
+	}
+	if options.RateLimit > 0 {
+		content = &rateLimitedReader{ReadSeeker: content, rate: options.RateLimit, ctx: ctx.Request.Context()}
+	}
+	http.ServeContent(ctx.ResponseWriter, ctx.Request, options.Name, modtime, content)
+}
+
+// contentDisposition format Content-Disposition of RFC 6266 with an ascii filename and, for other
+// names, the utf-8 filename* of RFC 8187
+func contentDisposition(disposition, name string) string {
+	if name == "" {
+		return disposition
+	}
+	var fallback strings.Builder
+	ascii := true
+	for _, r := range name {
+		switch {
+		case r < 0x20 || r == 0x7f || r == '"' || r == '\\' || r == '/':
+			fallback.WriteByte('_')
+		case r > 0x7e:
+			ascii = false
+			fallback.WriteByte('_')
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+	s := disposition + `; filename="` + fallback.String() + `"`
+	if ascii {
+		return s
+	}
+	var encoded strings.Builder
+	for _, b := range []byte(name) {
+		if isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return s + "; filename*=UTF-8''" + encoded.String()
+}
+
+// isAttrChar attr-char of RFC 8187
+func isAttrChar(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' || strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
+
+// rateLimitedReader read at most rate bytes per second since the first read
+type rateLimitedReader struct {
+	io.ReadSeeker
+	rate  int64
+	ctx   context.Context
+	start time.Time
+	read  int64
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+	if int64(len(p)) > r.rate {
+		p = p[:r.rate]
+	}
+	n, err := r.ReadSeeker.Read(p)
+	r.read += int64(n)
+	wait := time.Duration(float64(r.read)/float64(r.rate)*float64(time.Second)) - time.Since(r.start)
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.ctx.Done():
+			return n, r.ctx.Err()
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,106 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/corex-io/web"
+)
+
+func TestDownload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(file, []byte("0123456789abcdef"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{"static/logo.svg": {Data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), ModTime: modtime}}
+
+	app := web.New(web.DisableAccessLog())
+	app.RouteFunc("/file", func(ctx *web.Context) {
+		ctx.Download(file, map[string]string{"X-Extra": "1"})
+	})
+	app.RouteFunc("/missing", func(ctx *web.Context) {
+		ctx.DownloadFile(filepath.Join(dir, "missing.txt"))
+	})
+	app.RouteFunc("/fs", func(ctx *web.Context) {
+		ctx.DownloadFS(fsys, "static/logo.svg", web.DownloadInline())
+	})
+	app.RouteFunc("/content", func(ctx *web.Context) {
+		ctx.DownloadContent("résumé 2024.pdf", time.Time{}, strings.NewReader("%PDF-1.4"))
+	})
+	app.RouteFunc("/slow", func(ctx *web.Context) {
+		ctx.DownloadContent("slow.bin", time.Time{}, strings.NewReader(strings.Repeat("x", 100)), web.DownloadRateLimit(500))
+	})
+
+	serve := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/file", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789abcdef" || etag == "" ||
+		w.Header().Get("Content-Disposition") != `attachment; filename="report.txt"` ||
+		w.Header().Get("Content-Type") != "text/plain; charset=utf-8" || w.Header().Get("X-Extra") != "1" ||
+		w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("unexpected response %d %v %q", w.Code, w.Header(), w.Body)
+	}
+
+	if w := serve("/file", map[string]string{"Range": "bytes=2-5"}); w.Code != http.StatusPartialContent ||
+		w.Body.String() != "2345" || w.Header().Get("Content-Range") != "bytes 2-5/16" {
+		t.Errorf("unexpected range %d %v %q", w.Code, w.Header(), w.Body)
+	}
+	w = serve("/file", map[string]string{"Range": "bytes=0-1,-2"})
+	if w.Code != http.StatusPartialContent || !strings.HasPrefix(w.Header().Get("Content-Type"), "multipart/byteranges") ||
+		!strings.Contains(w.Body.String(), "01") || !strings.Contains(w.Body.String(), "ef") {
+		t.Errorf("unexpected multi-range %d %v", w.Code, w.Header())
+	}
+	if w := serve("/file", map[string]string{"Range": "bytes=2-5", "If-Range": etag}); w.Code != http.StatusPartialContent {
+		t.Errorf("expect 206 for matching If-Range, got %d", w.Code)
+	}
+	if w := serve("/file", map[string]string{"Range": "bytes=2-5", "If-Range": `"stale"`}); w.Code != http.StatusOK || w.Body.Len() != 16 {
+		t.Errorf("expect full 200 for stale If-Range, got %d", w.Code)
+	}
+	if w := serve("/file", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Errorf("expect 304, got %d", w.Code)
+	}
+	if w := serve("/file", map[string]string{"Range": "bytes=100-"}); w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("expect 416, got %d", w.Code)
+	}
+	if w := serve("/missing", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expect 404, got %d", w.Code)
+	}
+
+	w = serve("/fs", map[string]string{"If-Modified-Since": modtime.Format(http.TimeFormat)})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expect 304 for fs, got %d", w.Code)
+	}
+	w = serve("/fs", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/svg+xml" ||
+		w.Header().Get("Content-Disposition") != `inline; filename="logo.svg"` {
+		t.Errorf("unexpected fs response %d %v", w.Code, w.Header())
+	}
+
+	w = serve("/content", nil)
+	if w.Header().Get("Content-Disposition") != `attachment; filename="r_sum_ 2024.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9%202024.pdf` ||
+		w.Header().Get("Content-Type") != "application/pdf" || w.Header().Get("ETag") != "" {
+		t.Errorf("unexpected content response %v", w.Header())
+	}
+
+	start := time.Now()
+	if w := serve("/slow", nil); w.Body.Len() != 100 || time.Since(start) < 150*time.Millisecond {
+		t.Errorf("download not rate limited: %d bytes in %s", w.Body.Len(), time.Since(start))
+	}
+}
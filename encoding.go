@@ -0,0 +1,43 @@
+package web
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLRenderer encode v as yaml by gopkg.in/yaml.v3, fields named by the `yaml` tags
+func YAMLRenderer(w io.Writer, v interface{}) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// MsgpackRenderer encode v as MessagePack by vmihailenco/msgpack with sorted map keys, fields named by
+// the `msgpack` tags, or the `json` tags if absent
+func MsgpackRenderer(w io.Writer, v interface{}) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetSortMapKeys(true)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+// ProtobufRenderer encode v by proto.Marshal, v must be a proto.Message
+func ProtobufRenderer(w io.Writer, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf: unsupported type %T, expect proto.Message", v)
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"encoding/json"
+)
+
+// Envelope default json response shape
+type Envelope struct {
+	Code int         `json:"code"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data"`
+}
+
+// EnvelopeFunc wrap the data, business code and error of Context.JSON into the value encoded as response
+type EnvelopeFunc func(v interface{}, code int, err error) interface{}
+
+// DefaultEnvelope wrap as {"code": code, "msg": "success" or err, "data": v}
+func DefaultEnvelope(v interface{}, code int, err error) interface{} {
+	msg := "success"
+	if err != nil {
+		msg = err.Error()
+	}
+	return &Envelope{Code: code, Msg: msg, Data: jsonData(v)}
+}
+
+// RawEnvelope encode v as is, or {"error": err} if v is nil
+func RawEnvelope(v interface{}, code int, err error) interface{} {
+	if v == nil && err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+	return jsonData(v)
+}
+
+// jsonData embed []byte and string holding valid json as is, others are encoded as json string
+func jsonData(v interface{}) interface{} {
+	switch data := v.(type) {
+	case []byte:
+		if json.Valid(data) {
+			return json.RawMessage(data)
+		}
+		return string(data)
+	case string:
+		if json.Valid([]byte(data)) {
+			return json.RawMessage(data)
+		}
+		return data
+	}
+	return v
+}
+
+// SetEnvelope set the envelope of Context.JSON
+func (s *Web) SetEnvelope(f EnvelopeFunc) {
+	s.Envelope = f
+}
@@ -0,0 +1,51 @@
+package web_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/corex-io/web"
+)
+
+func TestJSONEnvelope(t *testing.T) {
+	app := web.New()
+	app.RouteFunc("/error", func(ctx *web.Context) {
+		ctx.JSONStatus(http.StatusConflict, `not "json"`, 42, errors.New("quote \" and\nnewline"))
+	})
+	app.RouteFunc("/raw", func(ctx *web.Context) {
+		ctx.JSON([]byte(`{"a":1}`), 0, nil)
+	})
+	app.RouteFunc("/unencodable", func(ctx *web.Context) {
+		ctx.JSON(make(chan int), 0, nil)
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := serve("/error")
+	var env web.Envelope
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatalf("invalid json %s: %v", w.Body, err)
+	}
+	if w.Code != http.StatusConflict || env.Code != 42 || env.Msg != "quote \" and\nnewline" || env.Data != `not "json"` {
+		t.Errorf("unexpected response %d %+v", w.Code, env)
+	}
+
+	if w := serve("/raw"); w.Body.String() != `{"code":0,"msg":"success","data":{"a":1}}`+"\n" {
+		t.Errorf("unexpected response %s", w.Body)
+	}
+	if w := serve("/unencodable"); w.Code != http.StatusInternalServerError {
+		t.Errorf("expect 500, got %d", w.Code)
+	}
+
+	app.SetEnvelope(web.RawEnvelope)
+	if w := serve("/raw"); w.Body.String() != `{"a":1}`+"\n" {
+		t.Errorf("unexpected raw response %s", w.Body)
+	}
+}
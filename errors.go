@@ -0,0 +1,234 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// HTTPError error carrying http status, business code, message and details for the response
+type HTTPError struct {
+	Status  int         // http status, 500 if 0
+	Code    int         // business code, Status if 0
+	Message string      // message for the client, http status text if empty
+	Details interface{} // extra data for the client
+	Err     error       // internal cause, logged but not sent to the client
+}
+
+// NewHTTPError new http error
+func NewHTTPError(status int, message string, details ...interface{}) *HTTPError {
+	e := &HTTPError{Status: status, Message: message}
+	if len(details) != 0 {
+		e.Details = details[0]
+	}
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	msg := e.message()
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap return the internal cause
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap set the internal cause
+func (e *HTTPError) Wrap(err error) *HTTPError {
+	e.Err = err
+	return e
+}
+
+func (e *HTTPError) status() int {
+	if e.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.Status
+}
+
+func (e *HTTPError) code() int {
+	if e.Code == 0 {
+		return e.status()
+	}
+	return e.Code
+}
+
+func (e *HTTPError) message() string {
+	if e.Message == "" {
+		return http.StatusText(e.status())
+	}
+	return e.Message
+}
+
+// StatusClientClosedRequest status recorded for requests the client canceled, as nginx logs them
+const StatusClientClosedRequest = 499
+
+// ToHTTPError map err to *HTTPError: *HTTPError as is, *BindError to 400, not exist to 404,
+// permission to 403, deadline exceeded to 503 like Timeout, canceled to 499, others to 500 hiding
+// the message
+func ToHTTPError(err error) *HTTPError {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	var bindErr *BindError
+	if errors.As(err, &bindErr) {
+		return &HTTPError{Status: http.StatusBadRequest, Message: "invalid request", Details: bindErr, Err: err}
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return &HTTPError{Status: http.StatusNotFound, Err: err}
+	case errors.Is(err, os.ErrPermission):
+		return &HTTPError{Status: http.StatusForbidden, Err: err}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &HTTPError{Status: http.StatusServiceUnavailable, Message: "request timeout", Err: err}
+	case errors.Is(err, context.Canceled):
+		return &HTTPError{Status: StatusClientClosedRequest, Message: "client closed request", Err: err}
+	}
+	return &HTTPError{Status: http.StatusInternalServerError, Err: err}
+}
+
+// HandlerFuncE handler func returning error, which is responded by Web.ErrorHandler
+type HandlerFuncE func(*Context) error
+
+func (f HandlerFuncE) serve(ctx *Context) {
+	if err := f(ctx); err != nil {
+		ctx.HandleError(err)
+	}
+}
+
+// Init init
+func (f HandlerFuncE) Init(ctx *Context) {}
+
+// Prepare Prepare
+func (f HandlerFuncE) Prepare(ctx *Context) {}
+
+// CONNECT CONNECT
+func (f HandlerFuncE) CONNECT(ctx *Context) { f.serve(ctx) }
+
+// OPTIONS OPTIONS
+func (f HandlerFuncE) OPTIONS(ctx *Context) { f.serve(ctx) }
+
+// HEAD HEAD
+func (f HandlerFuncE) HEAD(ctx *Context) { f.serve(ctx) }
+
+// GET GET
+func (f HandlerFuncE) GET(ctx *Context) { f.serve(ctx) }
+
+// POST POST
+func (f HandlerFuncE) POST(ctx *Context) { f.serve(ctx) }
+
+// DELETE DELETE
+func (f HandlerFuncE) DELETE(ctx *Context) { f.serve(ctx) }
+
+// PUT PUT
+func (f HandlerFuncE) PUT(ctx *Context) { f.serve(ctx) }
+
+// TRACE TRACE
+func (f HandlerFuncE) TRACE(ctx *Context) { f.serve(ctx) }
+
+// PATCH PATCH
+func (f HandlerFuncE) PATCH(ctx *Context) { f.serve(ctx) }
+
+// Finish Finish
+func (f HandlerFuncE) Finish(ctx *Context) {}
+
+// RouteFuncE route handler func returning error
+func (s *Web) RouteFuncE(path string, f HandlerFuncE) {
+	s.Route(path, f)
+}
+
+// ErrorHandlerFunc respond err returned by a handler
+type ErrorHandlerFunc func(ctx *Context, err error)
+
+// SetErrorHandler set the handler of errors returned by handlers
+func (s *Web) SetErrorHandler(f ErrorHandlerFunc) {
+	s.ErrorHandler = f
+}
+
+// HandleError respond err by the ErrorHandler of Web
+func (ctx *Context) HandleError(err error) {
+	ctx.checkReleased()
+	if ctx.web != nil && ctx.web.ErrorHandler != nil {
+		ctx.web.ErrorHandler(ctx, err)
+		return
+	}
+	DefaultErrorHandler(ctx, err)
+}
+
+// DefaultErrorHandler respond err mapped by ToHTTPError in the json envelope, or in problem+json
+// if the client prefers it by NegotiateType. Only log err if the response already started.
+func DefaultErrorHandler(ctx *Context, err error) {
+	if NegotiateType(ctx.Request.Header.Values("Accept"), "application/json", "application/problem+json") == "application/problem+json" {
+		ProblemErrorHandler(ctx, err)
+		return
+	}
+	httpErr := ToHTTPError(err)
+	if !logHandlerError(ctx, httpErr) {
+		return
+	}
+	ctx.JSONStatus(httpErr.status(), httpErr.Details, httpErr.code(), errors.New(httpErr.message()))
+}
+
+// Problem RFC 7807 problem details, with the business code and details as extensions
+type Problem struct {
+	Type     string      `json:"type"`
+	Title    string      `json:"title"`
+	Status   int         `json:"status"`
+	Detail   string      `json:"detail,omitempty"`
+	Instance string      `json:"instance,omitempty"`
+	Code     int         `json:"code,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+// ProblemErrorHandler respond err mapped by ToHTTPError as application/problem+json
+func ProblemErrorHandler(ctx *Context, err error) {
+	httpErr := ToHTTPError(err)
+	if !logHandlerError(ctx, httpErr) {
+		return
+	}
+	status := httpErr.status()
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: ctx.URL.Path,
+		Details:  httpErr.Details,
+	}
+	if httpErr.Message != "" && httpErr.Message != problem.Title {
+		problem.Detail = httpErr.Message
+	}
+	if httpErr.Code != 0 {
+		problem.Code = httpErr.Code
+	}
+	b, jsonErr := json.Marshal(problem)
+	if jsonErr != nil {
+		ctx.Logger.Errorf("json encode: %v", jsonErr)
+		ctx.Error(http.StatusInternalServerError)
+		return
+	}
+	ctx.SetStatusCode(status)
+	ctx.ResponseWriter.Header().Set("Content-Type", "application/problem+json")
+	ctx.ResponseWriter.Header().Set("Content-Length", fmt.Sprint(len(b)))
+	ctx.ResponseWriter.WriteHeader(status)
+	_, _ = ctx.ResponseWriter.Write(b)
+}
+
+// logHandlerError log server errors, return whether the response can still be written
+func logHandlerError(ctx *Context, err *HTTPError) bool {
+	if err.status() >= http.StatusInternalServerError {
+		ctx.Logger.Errorf("%s %s: %v", ctx.Method, ctx.URL.Path, err)
+	}
+	if ctx.Written() {
+		ctx.Logger.Warnf("%s %s: response already started, drop error %v", ctx.Method, ctx.URL.Path, err)
+		return false
+	}
+	return true
+}
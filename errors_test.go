@@ -0,0 +1,92 @@
+package web_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/corex-io/web"
+)
+
+func TestErrorHandler(t *testing.T) {
+	app := web.New()
+	app.RouteFuncE("/conflict", func(ctx *web.Context) error {
+		return web.NewHTTPError(http.StatusConflict, "name taken", map[string]string{"name": "bob"})
+	})
+	app.RouteFuncE("/missing", func(ctx *web.Context) error {
+		return fmt.Errorf("open: %w", os.ErrNotExist)
+	})
+	app.RouteFuncE("/internal", func(ctx *web.Context) error {
+		return errors.New("db password wrong")
+	})
+	app.RouteFuncE("/ok", func(ctx *web.Context) error {
+		ctx.JSON("done", 0, nil)
+		return nil
+	})
+	app.RouteFuncE("/written", func(ctx *web.Context) error {
+		ctx.JSON("partial", 0, nil)
+		return errors.New("late")
+	})
+
+	serve := func(path, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/conflict", "")
+	var env web.Envelope
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatalf("invalid json %s: %v", w.Body, err)
+	}
+	if w.Code != http.StatusConflict || env.Code != http.StatusConflict || env.Msg != "name taken" {
+		t.Errorf("unexpected response %d %s", w.Code, w.Body)
+	}
+	if w := serve("/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expect 404, got %d", w.Code)
+	}
+	if w := serve("/internal", ""); w.Code != http.StatusInternalServerError || json.Unmarshal(w.Body.Bytes(), &env) != nil || env.Msg != "Internal Server Error" {
+		t.Errorf("internal error leaked or wrong: %d %s", w.Code, w.Body)
+	}
+	if w := serve("/ok", ""); w.Code != http.StatusOK {
+		t.Errorf("expect 200, got %d", w.Code)
+	}
+	if w := serve("/written", ""); w.Code != http.StatusOK {
+		t.Errorf("expect written response kept, got %d %s", w.Code, w.Body)
+	}
+
+	w = serve("/conflict", "application/problem+json")
+	var problem web.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("invalid json %s: %v", w.Body, err)
+	}
+	if w.Header().Get("Content-Type") != "application/problem+json" || problem.Status != http.StatusConflict ||
+		problem.Title != "Conflict" || problem.Detail != "name taken" || problem.Instance != "/conflict" {
+		t.Errorf("unexpected problem %s %s", w.Header().Get("Content-Type"), w.Body)
+	}
+
+	for accept, contentType := range map[string]string{
+		"application/problem+json;q=0, application/json":   "application/json;charset=UTF-8",
+		"application/json;q=0.5, application/problem+json": "application/problem+json",
+		"application/*": "application/json;charset=UTF-8",
+	} {
+		if w := serve("/conflict", accept); w.Header().Get("Content-Type") != contentType {
+			t.Errorf("Accept %q: expect %s, got %s", accept, contentType, w.Header().Get("Content-Type"))
+		}
+	}
+
+	app.SetErrorHandler(func(ctx *web.Context, err error) {
+		ctx.Error(http.StatusTeapot)
+	})
+	if w := serve("/internal", ""); w.Code != http.StatusTeapot {
+		t.Errorf("custom error handler not used, got %d", w.Code)
+	}
+}
@@ -4,7 +4,13 @@ go 1.18
 
 require (
 	github.com/corex-io/log v0.0.0-20191029091020-768e1f3b9e33
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	golang.org/x/net v0.0.0-20191028085509-fe3aa8a45271
+	google.golang.org/protobuf v1.33.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/text v0.3.0 // indirect
+require (
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	golang.org/x/text v0.3.0 // indirect
+)
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Group routes sharing a path prefix and middlewares, run after the global ones of Web
+type Group struct {
+	web    *Web
+	parent *Group
+	prefix string
+	mids   []func(*Context)
+}
+
+// Group new route group, pattern registered by the group is prefixed by prefix
+func (s *Web) Group(prefix string, mids ...func(*Context)) *Group {
+	return &Group{web: s, prefix: prefix, mids: mids}
+}
+
+// Group new nested route group
+func (g *Group) Group(prefix string, mids ...func(*Context)) *Group {
+	return &Group{web: g.web, parent: g, prefix: g.prefix + prefix, mids: mids}
+}
+
+// Use append middleware of group
+func (g *Group) Use(f ...func(*Context)) {
+	g.mids = append(g.mids, f...)
+}
+
+// Prefix return the full path prefix of group
+func (g *Group) Prefix() string {
+	return g.prefix
+}
+
+// Handle http handle
+func (g *Group) Handle(path string, handler http.Handler) {
+	g.Route(path, warpHandlerFunc(handler.ServeHTTP))
+}
+
+// HandleFunc http Handle func
+func (g *Group) HandleFunc(path string, f http.HandlerFunc) {
+	g.Route(path, warpHandlerFunc(f))
+}
+
+// Route handle, regex path like `^/users$` becomes `^<prefix>/users$`, plain paths are matched exactly
+// by Tree
+func (g *Group) Route(path string, handle Handler) {
+	g.web.route(g.pattern(path), handle, g)
+}
+
+// RouteFunc route handlerfunc
+func (g *Group) RouteFunc(path string, f HandlerFunc) {
+	g.Route(path, f)
+}
+
+// RouteFuncE route handler func returning error
+func (g *Group) RouteFuncE(path string, f HandlerFuncE) {
+	g.Route(path, f)
+}
+
+func (g *Group) pattern(path string) string {
+	if isRegexPattern(path) {
+		return "^" + regexp.QuoteMeta(g.prefix) + strings.TrimPrefix(path, "^")
+	}
+	return g.prefix + path
+}
+
+// middlewares return middlewares of group and its parents, outermost first
+func (g *Group) middlewares() []func(*Context) {
+	if g.parent == nil {
+		return g.mids
+	}
+	return append(append([]func(*Context){}, g.parent.middlewares()...), g.mids...)
+}
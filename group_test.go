@@ -0,0 +1,51 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/corex-io/web"
+	"github.com/corex-io/web/middleware"
+)
+
+func TestGroup(t *testing.T) {
+	app := web.New()
+	ok := func(ctx *web.Context) { ctx.Text([]byte(ctx.URL.Path)) }
+	app.RouteFunc("/public", ok)
+
+	admin := app.Group("/admin", middleware.AccessIP("10.0.0.0/8"))
+	admin.RouteFunc("/users", ok)
+	admin.RouteFunc("^/regex/(?P<id>\\d+)$", ok)
+	admin.RouteFuncE("/locked", func(ctx *web.Context) error {
+		return web.NewHTTPError(http.StatusLocked, "locked")
+	})
+
+	api := app.Group("/api")
+	v1 := api.Group("/v1")
+	v1.RouteFunc("/items/:id", ok)
+	v1.Use(func(ctx *web.Context) { ctx.Error(http.StatusTeapot) })
+
+	cases := []struct {
+		path, remote string
+		code         int
+	}{
+		{"/public", "192.0.2.1:1234", http.StatusOK},
+		{"/admin/users", "192.0.2.1:1234", http.StatusForbidden},
+		{"/admin/users", "10.1.2.3:1234", http.StatusOK},
+		{"/admin/regex/12", "10.1.2.3:1234", http.StatusOK},
+		{"/admin/locked", "10.1.2.3:1234", http.StatusLocked},
+		{"/admin/locked", "192.0.2.1:1234", http.StatusForbidden},
+		{"/regex/12", "10.1.2.3:1234", http.StatusNotFound},
+		{"/api/v1/items/1", "192.0.2.1:1234", http.StatusTeapot},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.RemoteAddr = c.remote
+		app.ServeHTTP(w, req)
+		if w.Code != c.code {
+			t.Errorf("%s from %s: expect %d, got %d", c.path, c.remote, c.code, w.Code)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// Listener one address served by Web, zero timeouts fall back to the ones of Options
+type Listener struct {
+	Network           string // tcp (default) or unix
+	Address           string // host:port, or socket path for unix
+	CertFile          string
+	KeyFile           string
+	ReadTimeout       int
+	ReadHeaderTimeout int
+	WriteTimeout      int
+	IdleTimeout       int
+	RedirectHTTPS     string   // if set, redirect every request to https on this port instead of serving
+	MinVersion        string   // minimum tls version: 1.0, 1.1, 1.2 (default) or 1.3
+	CipherSuites      []string // tls cipher suite names like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, default by crypto/tls
+	ClientCAFile      string   // CA bundle to verify client certificates, enables mutual tls
+	ClientAuth        string   // request, require, verify-if-given or require-and-verify (default if ClientCAFile set)
+}
+
+// Listen serve the listeners instead of Options.Address
+func Listen(listeners ...Listener) Option {
+	return func(o *Options) {
+		o.Listeners = append(o.Listeners, listeners...)
+	}
+}
+
+func (l Listener) network() string {
+	if l.Network == "" {
+		return "tcp"
+	}
+	return l.Network
+}
+
+func (l Listener) isTLS() bool {
+	return l.CertFile != "" && l.KeyFile != ""
+}
+
+func (l Listener) String() string {
+	scheme := "http"
+	switch {
+	case l.network() == "unix":
+		scheme = "unix"
+	case l.isTLS():
+		scheme = "https"
+	}
+	return scheme + "://" + l.Address
+}
+
+func (l Listener) listen() (net.Listener, error) {
+	if l.network() == "unix" {
+		// remove socket file left by an unclean exit, but never another kind of file
+		info, err := os.Lstat(l.Address)
+		switch {
+		case err == nil && info.Mode()&os.ModeSocket == 0:
+			return nil, fmt.Errorf("listen unix %s: file exists and is not a socket", l.Address)
+		case err == nil:
+			if err := os.Remove(l.Address); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+	}
+	return net.Listen(l.network(), l.Address)
+}
+
+// listeners return Options.Listeners, or the single listener of Options.Address
+func (o Options) listeners() []Listener {
+	if len(o.Listeners) != 0 {
+		return o.Listeners
+	}
+	return []Listener{{
+		Address:  o.Address,
+		CertFile: o.CertFile,
+		KeyFile:  o.KeyFile,
+	}}
+}
+
+func (s *Web) newServer(l Listener) (*http.Server, error) {
+	seconds := func(v, def int) time.Duration {
+		if v == 0 {
+			v = def
+		}
+		return time.Duration(v) * time.Second
+	}
+	var handler http.Handler = s
+	if l.RedirectHTTPS != "" {
+		handler = redirectHTTPS(l.RedirectHTTPS)
+	}
+	server := &http.Server{
+		Addr:              l.Address,
+		Handler:           handler,
+		ReadTimeout:       seconds(l.ReadTimeout, s.opts.ReadTimeout),
+		ReadHeaderTimeout: seconds(l.ReadHeaderTimeout, s.opts.ReadHeaderTimeout),
+		WriteTimeout:      seconds(l.WriteTimeout, s.opts.WriteTimeout),
+		IdleTimeout:       seconds(l.IdleTimeout, s.opts.IdleTimeout),
+		MaxHeaderBytes:    s.opts.MaxHeaderBytes,
+	}
+	if l.isTLS() {
+		cfg, err := l.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = cfg
+	}
+	serverhttp2 := &http2.Server{
+		IdleTimeout: server.IdleTimeout,
+	}
+	if err := http2.ConfigureServer(server, serverhttp2); err != nil {
+		s.Log.Errorf("%v", err)
+	}
+	if l.isTLS() {
+		reloader, err := newCertReloader(l)
+		if err != nil {
+			return nil, err
+		}
+		useReloader(server.TLSConfig, reloader)
+		s.mu.Lock()
+		s.reloaders = append(s.reloaders, reloader)
+		s.mu.Unlock()
+	}
+	return server, nil
+}
+
+// redirectHTTPS redirect request to the same host and path with https scheme on port
+func redirectHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(req.Host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		target := "https://" + host + req.URL.RequestURI()
+		http.Redirect(resp, req, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,163 @@
+package web_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corex-io/web"
+)
+
+// writeCert write a self-signed certificate for 127.0.0.1, return cert and key file
+func writeCert(t *testing.T, dir, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestListeners(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "localhost")
+	httpAddr, httpsAddr, redirectAddr := freeAddress(t), freeAddress(t), freeAddress(t)
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+	sock := filepath.Join(dir, "web.sock")
+
+	app := web.New(web.Listen(
+		web.Listener{Address: httpAddr},
+		web.Listener{Address: httpsAddr, CertFile: certFile, KeyFile: keyFile},
+		web.Listener{Network: "unix", Address: sock},
+		web.Listener{Address: redirectAddr, RedirectHTTPS: httpsPort},
+	))
+	app.HealthCheck("/healthz")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run(ctx) }()
+	waitReady(t, "http://"+httpAddr+"/healthz")
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get("https://" + httpsAddr + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS == nil {
+		t.Errorf("https: expect 200 over tls, got %d", resp.StatusCode)
+	}
+
+	resp, err = client.Get("http://" + redirectAddr + "/healthz?a=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if expect := "https://127.0.0.1:" + httpsPort + "/healthz?a=1"; resp.Header.Get("Location") != expect {
+		t.Errorf("redirect: expect %s, got %d %s", expect, resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	unix := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err = unix.Get("http://unix/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unix: expect 200, got %d", resp.StatusCode)
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("expect nil on orderly stop, got %v", err)
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("unix socket not removed: %v", err)
+	}
+}
+
+func TestListenerUnixNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	if err := ioutil.WriteFile(path, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := web.New(web.DisableAccessLog(), web.Listen(web.Listener{Network: "unix", Address: path}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := app.Run(ctx); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("expect not a socket error, got %v", err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "keep" {
+		t.Errorf("file at the socket path removed: %q %v", b, err)
+	}
+
+	// a socket left by an unclean exit is replaced
+	sock := filepath.Join(t.TempDir(), "web.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	app = web.New(web.DisableAccessLog(), web.Listen(web.Listener{Network: "unix", Address: sock}))
+	ctx, cancel = context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run(ctx) }()
+	deadline := time.Now().Add(2 * time.Second)
+	for !app.Ready() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("expect the stale socket replaced, got %v", err)
+	}
+}
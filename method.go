@@ -0,0 +1,79 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// handlerMethods http methods dispatched to Handler, in Allow header order
+var handlerMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// Allower is implemented by a Handler which reports the http methods it accepts itself. Handlers
+// embedding BaseHandler implement it to have 405 with the Allow header, OPTIONS and HEAD answered for
+// them, otherwise every method is dispatched to them.
+type Allower interface {
+	Allow() []string
+}
+
+// Methods restrict a HandlerFunc to the given http methods, others response 405
+func Methods(f HandlerFunc, methods ...string) Handler {
+	return methodsHandler{HandlerFunc: f, methods: methods}
+}
+
+type methodsHandler struct {
+	HandlerFunc
+	methods []string
+}
+
+// Allow allow
+func (h methodsHandler) Allow() []string {
+	return h.methods
+}
+
+// implementedMethods return the http methods handler implements itself.
+// HandlerFunc and HandlerFuncE accept every method but OPTIONS, which is answered for them, wrapped
+// http.Handler every method, others the methods of Allower or every method if not implemented.
+func implementedMethods(handler Handler) map[string]bool {
+	implements := make(map[string]bool, len(handlerMethods))
+	switch h := handler.(type) {
+	case Allower:
+		for _, method := range h.Allow() {
+			implements[strings.ToUpper(method)] = true
+		}
+	case HandlerFunc, HandlerFuncE:
+		for _, method := range handlerMethods {
+			implements[method] = method != http.MethodOptions
+		}
+	default:
+		for _, method := range handlerMethods {
+			implements[method] = true
+		}
+	}
+	return implements
+}
+
+// allowHeader return the value of Allow header, HEAD is derived from GET and OPTIONS is always answered
+func allowHeader(implements map[string]bool) string {
+	var allow []string
+	for _, method := range handlerMethods {
+		switch {
+		case implements[method]:
+		case method == http.MethodHead && implements[http.MethodGet]:
+		case method == http.MethodOptions:
+		default:
+			continue
+		}
+		allow = append(allow, method)
+	}
+	return strings.Join(allow, ", ")
+}
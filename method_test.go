@@ -0,0 +1,81 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/corex-io/web"
+)
+
+type getOnly struct {
+	web.BaseHandler
+}
+
+func (*getOnly) Allow() []string {
+	return []string{http.MethodGet}
+}
+
+func (*getOnly) GET(ctx *web.Context) {
+	ctx.Text([]byte("get"))
+}
+
+type embedGetOnly struct {
+	getOnly
+}
+
+func (embedGetOnly) Allow() []string {
+	return []string{http.MethodGet, http.MethodPost}
+}
+
+func (embedGetOnly) POST(ctx *web.Context) {
+	ctx.Text([]byte("post"))
+}
+
+// undeclared embeds BaseHandler without reporting its methods
+type undeclared struct {
+	web.BaseHandler
+}
+
+func (*undeclared) GET(ctx *web.Context) {
+	ctx.Text([]byte("get"))
+}
+
+func TestMethods(t *testing.T) {
+	app := web.New()
+	app.Route("/get", &getOnly{})
+	app.Route("/embed", &embedGetOnly{})
+	app.Route("/undeclared", &undeclared{})
+	app.RouteFunc("/func", func(ctx *web.Context) { ctx.Text([]byte(ctx.Method)) })
+	app.Route("/restricted", web.Methods(func(ctx *web.Context) {}, http.MethodPut))
+
+	cases := []struct {
+		method, path string
+		code         int
+		allow        string
+	}{
+		{http.MethodGet, "/get", http.StatusOK, ""},
+		{http.MethodHead, "/get", http.StatusOK, ""},
+		{http.MethodPost, "/get", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{http.MethodOptions, "/get", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{"PROPFIND", "/get", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{http.MethodPost, "/embed", http.StatusOK, ""},
+		{http.MethodDelete, "/embed", http.StatusMethodNotAllowed, "GET, HEAD, POST, OPTIONS"},
+		{http.MethodGet, "/undeclared", http.StatusOK, ""},
+		{http.MethodPost, "/undeclared", http.StatusMethodNotAllowed, ""},
+		{http.MethodDelete, "/func", http.StatusOK, ""},
+		{http.MethodOptions, "/func", http.StatusNoContent, "GET, HEAD, POST, PUT, PATCH, DELETE, CONNECT, OPTIONS, TRACE"},
+		{"PROPFIND", "/func", http.StatusMethodNotAllowed, "GET, HEAD, POST, PUT, PATCH, DELETE, CONNECT, OPTIONS, TRACE"},
+		{http.MethodGet, "/restricted", http.StatusMethodNotAllowed, "PUT, OPTIONS"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.code {
+			t.Errorf("%s %s: expect %d, got %d", c.method, c.path, c.code, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("%s %s: expect Allow %q, got %q", c.method, c.path, c.allow, allow)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets upper bounds in seconds of the request duration histogram
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets upper bounds in bytes of the response size histogram
+var DefaultSizeBuckets = []float64{100, 1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20}
+
+// route labels of requests matching no route, and of files served by Options.StaticPaths
+const (
+	unmatchedRoute = "unmatched"
+	staticRoute    = "static"
+)
+
+// Metrics request metrics by route pattern, served in Prometheus text exposition format
+type Metrics struct {
+	LatencyBuckets []float64
+	SizeBuckets    []float64
+
+	inflight  int64
+	mu        sync.Mutex
+	requests  map[[3]string]uint64 // method, route, status
+	durations map[[2]string]*histogram
+	sizes     map[[2]string]*histogram
+	panics    map[[2]string]uint64
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics new metrics with default buckets
+func NewMetrics() *Metrics {
+	return &Metrics{
+		LatencyBuckets: DefaultLatencyBuckets,
+		SizeBuckets:    DefaultSizeBuckets,
+		requests:       make(map[[3]string]uint64),
+		durations:      make(map[[2]string]*histogram),
+		sizes:          make(map[[2]string]*histogram),
+		panics:         make(map[[2]string]uint64),
+	}
+}
+
+// Metrics record metrics of every request and serve them on path
+func (s *Web) Metrics(path string) *Metrics {
+	s.metrics = NewMetrics()
+	s.Handle(path, s.metrics)
+	return s.metrics
+}
+
+func (m *Metrics) begin() {
+	atomic.AddInt64(&m.inflight, 1)
+}
+
+// end record a served request, by the route pattern not the raw path to bound cardinality
+func (m *Metrics) end(ctx *Context) {
+	atomic.AddInt64(&m.inflight, -1)
+	route := unmatchedRoute
+	switch {
+	case ctx.entry != nil:
+		route = ctx.entry.pattern
+	case ctx.static:
+		route = staticRoute
+	}
+	status := ctx.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	method := metricMethod(ctx.Method)
+	key := [2]string{method, route}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[3]string{method, route, strconv.Itoa(status)}]++
+	observe(m.durations, key, m.LatencyBuckets, time.Since(ctx.Timestamp).Seconds())
+	observe(m.sizes, key, m.SizeBuckets, float64(ctx.Size()))
+	if ctx.panic != nil {
+		m.panics[key]++
+	}
+}
+
+// metricMethod return the method label, OTHER for methods not defined by net/http, so clients can't
+// add labels at will
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+func observe(hs map[[2]string]*histogram, key [2]string, buckets []float64, v float64) {
+	h, ok := hs[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(buckets))}
+		hs[key] = h
+	}
+	h.sum += v
+	h.count++
+	if i := sort.SearchFloat64s(buckets, v); i < len(buckets) {
+		h.counts[i]++
+	}
+}
+
+// ServeHTTP serve metrics in Prometheus text exposition format
+func (m *Metrics) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	m.write(&buf)
+	resp.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = buf.WriteTo(resp)
+}
+
+func (m *Metrics) write(buf *bytes.Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	buf.WriteString("# HELP http_requests_in_flight Number of requests being served.\n")
+	buf.WriteString("# TYPE http_requests_in_flight gauge\n")
+	fmt.Fprintf(buf, "http_requests_in_flight %d\n", atomic.LoadInt64(&m.inflight))
+
+	buf.WriteString("# HELP http_requests_total Total number of requests by method, route and status.\n")
+	buf.WriteString("# TYPE http_requests_total counter\n")
+	keys := make([][3]string, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return strings.Join(keys[i][:], "\x00") < strings.Join(keys[j][:], "\x00")
+	})
+	for _, key := range keys {
+		fmt.Fprintf(buf, "http_requests_total{method=%s,route=%s,status=%s} %d\n",
+			labelValue(key[0]), labelValue(key[1]), labelValue(key[2]), m.requests[key])
+	}
+
+	writeHistograms(buf, "http_request_duration_seconds", "Request duration in seconds.", m.durations, m.LatencyBuckets)
+	writeHistograms(buf, "http_response_size_bytes", "Response body size in bytes.", m.sizes, m.SizeBuckets)
+
+	buf.WriteString("# HELP http_request_panics_total Total number of panics recovered while serving.\n")
+	buf.WriteString("# TYPE http_request_panics_total counter\n")
+	panics := make([][2]string, 0, len(m.panics))
+	for key := range m.panics {
+		panics = append(panics, key)
+	}
+	for _, key := range sortKeys(panics) {
+		fmt.Fprintf(buf, "http_request_panics_total{method=%s,route=%s} %d\n", labelValue(key[0]), labelValue(key[1]), m.panics[key])
+	}
+}
+
+func writeHistograms(buf *bytes.Buffer, name, help string, hs map[[2]string]*histogram, buckets []float64) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	keys := make([][2]string, 0, len(hs))
+	for key := range hs {
+		keys = append(keys, key)
+	}
+	for _, key := range sortKeys(keys) {
+		h := hs[key]
+		labels := fmt.Sprintf("method=%s,route=%s", labelValue(key[0]), labelValue(key[1]))
+		var cumulative uint64
+		for i, le := range buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(buf, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+func sortKeys(keys [][2]string) [][2]string {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+// labelValue quote label value, escaping backslash, double-quote and line feed
+func labelValue(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,53 @@
+package web_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/corex-io/web"
+)
+
+func TestMetrics(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "app.js"), []byte("js"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	app := web.New(web.DisableAccessLog(), web.StaticPath("/assets/", dir))
+	app.Metrics("/metrics")
+	app.RouteFunc("/users/:id", func(ctx *web.Context) { ctx.Text([]byte("hello")) })
+	app.RouteFunc("/panic", func(ctx *web.Context) { panic("boom") })
+
+	for _, path := range []string{"/users/1", "/users/2", "/panic", "/missing", "/assets/app.js"} {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	for _, method := range []string{"FOO", "BAR"} {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/users/1", nil))
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	for _, line := range []string{
+		`http_requests_in_flight 1`,
+		`http_requests_total{method="GET",route="/users/:id",status="200"} 2`,
+		`http_requests_total{method="GET",route="/panic",status="500"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`http_requests_total{method="GET",route="static",status="200"} 1`,
+		`http_requests_total{method="OTHER",route="/users/:id",status="405"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="/users/:id"} 2`,
+		`http_response_size_bytes_bucket{method="GET",route="/users/:id",le="100"} 2`,
+		`http_response_size_bytes_sum{method="GET",route="/users/:id"} 10`,
+		`http_request_panics_total{method="GET",route="/panic"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %s in\n%s", line, body)
+		}
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %s", ct)
+	}
+}
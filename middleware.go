@@ -0,0 +1,45 @@
+package web
+
+import "net/http"
+
+// Next run the rest of the middleware chain and the handler, then return to the caller,
+// so middleware can do work both before and after the handler. Middleware not calling
+// Next is followed by the rest of the chain once it returns, unless the response is
+// finished or Abort is called.
+func (ctx *Context) Next() {
+	ctx.checkReleased()
+	for ctx.index < len(ctx.handlers) {
+		if ctx.IsFinish() {
+			ctx.Abort()
+			return
+		}
+		handler := ctx.handlers[ctx.index]
+		ctx.index++
+		handler(ctx)
+	}
+}
+
+// Abort stop the rest of the middleware chain and the handler from running
+func (ctx *Context) Abort() {
+	ctx.index = len(ctx.handlers)
+}
+
+// RouteEntry return the matched route entry, nil before routing or if no route matched
+func (ctx *Context) RouteEntry() *Entry {
+	return ctx.entry
+}
+
+// WrapMiddleware adapt a net/http style middleware, the chain stops if it doesn't call next
+func WrapMiddleware(m func(http.Handler) http.Handler) func(*Context) {
+	return func(ctx *Context) {
+		next := false
+		m(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+			next = true
+			ctx.ResponseWriter, ctx.Request = resp, req
+			ctx.Next()
+		})).ServeHTTP(ctx.ResponseWriter, ctx.Request)
+		if !next {
+			ctx.Abort()
+		}
+	}
+}
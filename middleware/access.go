@@ -27,3 +27,19 @@ func AccessIP(cidrs ...string) func(*web.Context) {
 		ctx.Error(http.StatusForbidden)
 	}
 }
+
+// AccessCert access by the verified mutual tls client certificate, whose common name or SAN is one of names
+func AccessCert(names ...string) func(*web.Context) {
+	allow := make(map[string]bool, len(names))
+	for _, name := range names {
+		allow[name] = true
+	}
+	return func(ctx *web.Context) {
+		for _, name := range ctx.PeerNames() {
+			if allow[name] {
+				return
+			}
+		}
+		ctx.Error(http.StatusForbidden)
+	}
+}
@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/corex-io/log"
+	"github.com/corex-io/web"
+)
+
+// Access log formats
+const (
+	FormatCommon   = "common"
+	FormatCombined = "combined"
+	FormatJSON     = "json"
+)
+
+// AccessEntry one access log entry, fields are available to custom templates
+type AccessEntry struct {
+	Time      time.Time     `json:"time"`
+	RequestID string        `json:"request_id,omitempty"`
+	Remote    string        `json:"remote"`
+	Method    string        `json:"method"`
+	URI       string        `json:"uri"`
+	Proto     string        `json:"proto"`
+	Route     string        `json:"route,omitempty"`
+	Status    int           `json:"status"`
+	Bytes     int64         `json:"bytes"`
+	Latency   time.Duration `json:"latency"`
+	TTFB      time.Duration `json:"ttfb"`
+	Referer   string        `json:"referer,omitempty"`
+	UserAgent string        `json:"user_agent,omitempty"`
+}
+
+// AccessLogOptions options of AccessLog
+type AccessLogOptions struct {
+	Format   string     // common, combined (default), json, or a text/template of AccessEntry
+	Writer   io.Writer  // destination of log lines, used if Logger is nil
+	Logger   log.Logger // destination of log lines, Infof per line
+	Sample   float64    // fraction of requests logged in (0, 1], responses >= 500 are always logged
+	Excludes []string   // path prefixes not logged, like health checks and /debug/pprof
+}
+
+// AccessLogOption func
+type AccessLogOption func(*AccessLogOptions)
+
+// LogFormat set the format: common, combined, json, or a text/template of AccessEntry like `{{.Method}} {{.URI}} {{.Status}}`
+func LogFormat(format string) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.Format = format
+	}
+}
+
+// LogWriter write log lines to w
+func LogWriter(w io.Writer) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.Writer = w
+	}
+}
+
+// LogLogger write log lines to logger
+func LogLogger(logger log.Logger) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.Logger = logger
+	}
+}
+
+// LogSample log only a fraction of requests, responses >= 500 are always logged
+func LogSample(rate float64) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.Sample = rate
+	}
+}
+
+// LogExclude skip requests whose path begins with one of prefixes
+func LogExclude(prefixes ...string) AccessLogOption {
+	return func(o *AccessLogOptions) {
+		o.Excludes = append(o.Excludes, prefixes...)
+	}
+}
+
+// AccessLog log every request after it is served, and panics of the rest of the chain as 500,
+// replace the built-in access log of Web which can be turned off by web.DisableAccessLog.
+func AccessLog(opts ...AccessLogOption) func(*web.Context) {
+	options := AccessLogOptions{Format: FormatCombined, Sample: 1}
+	for _, o := range opts {
+		o(&options)
+	}
+	format := accessFormat(options.Format)
+	var mu sync.Mutex
+	output := func(line []byte) {
+		if options.Logger != nil {
+			options.Logger.Infof("%s", line)
+			return
+		}
+		if options.Writer != nil {
+			mu.Lock()
+			_, _ = options.Writer.Write(append(line, '\n'))
+			mu.Unlock()
+		}
+	}
+
+	return func(ctx *web.Context) {
+		for _, prefix := range options.Excludes {
+			if strings.HasPrefix(ctx.URL.Path, prefix) {
+				return
+			}
+		}
+		served := false
+		// deferred so panics of the rest of the chain are logged too
+		defer func() {
+			entry := NewAccessEntry(ctx)
+			if !served && !ctx.Written() {
+				// panicking, Web responds 500 after unless a Recovery outside this renders it
+				entry.Status = http.StatusInternalServerError
+			}
+			if entry.Status < 500 && options.Sample < 1 && rand.Float64() >= options.Sample {
+				return
+			}
+			line, err := format(entry)
+			if err != nil {
+				ctx.Logger.Errorf("access log: %v", err)
+				return
+			}
+			output(line)
+		}()
+		ctx.Next()
+		served = true
+	}
+}
+
+// NewAccessEntry return the access entry of a served request
+func NewAccessEntry(ctx *web.Context) *AccessEntry {
+	entry := &AccessEntry{
+		Time:      ctx.Timestamp,
+		RequestID: ctx.Trace().RequestID,
+		Remote:    ctx.Remote(),
+		Method:    ctx.Method,
+		URI:       ctx.RequestURI,
+		Proto:     ctx.Proto,
+		Status:    ctx.Status(),
+		Bytes:     ctx.Size(),
+		Latency:   time.Since(ctx.Timestamp),
+		TTFB:      ctx.TTFB(),
+		Referer:   ctx.Referer(),
+		UserAgent: ctx.UserAgent(),
+	}
+	if entry.RequestID == "" {
+		entry.RequestID = ctx.Request.Header.Get("X-Request-Id")
+	}
+	if entry.URI == "" {
+		entry.URI = ctx.URL.RequestURI()
+	}
+	if entry.Status == 0 {
+		entry.Status = 200
+	}
+	if route := ctx.RouteEntry(); route != nil {
+		entry.Route = route.Pattern()
+	}
+	return entry
+}
+
+func accessFormat(format string) func(*AccessEntry) ([]byte, error) {
+	switch format {
+	case FormatCommon:
+		return func(e *AccessEntry) ([]byte, error) {
+			return []byte(e.common()), nil
+		}
+	case FormatCombined:
+		return func(e *AccessEntry) ([]byte, error) {
+			return []byte(fmt.Sprintf("%s %q %q", e.common(), e.Referer, e.UserAgent)), nil
+		}
+	case FormatJSON:
+		return func(e *AccessEntry) ([]byte, error) {
+			return json.Marshal(e)
+		}
+	}
+	tpl := template.Must(template.New("accesslog").Parse(format))
+	return func(e *AccessEntry) ([]byte, error) {
+		var buf bytes.Buffer
+		err := tpl.Execute(&buf, e)
+		return buf.Bytes(), err
+	}
+}
+
+// common Apache common log format: host ident user [time] "request" status bytes
+func (e *AccessEntry) common() string {
+	size := "-"
+	if e.Bytes > 0 {
+		size = fmt.Sprint(e.Bytes)
+	}
+	return fmt.Sprintf(`%s - - [%s] "%s %s %s" %d %s`,
+		e.Remote, e.Time.Format("02/Jan/2006:15:04:05 -0700"), e.Method, e.URI, e.Proto, e.Status, size)
+}
@@ -0,0 +1,83 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/corex-io/web"
+	"github.com/corex-io/web/middleware"
+)
+
+func serve(app *web.Web, path string) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-Request-Id", "req-1")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func newApp(opts ...middleware.AccessLogOption) *web.Web {
+	app := web.New(web.DisableAccessLog())
+	app.Use(middleware.AccessLog(opts...))
+	app.RouteFunc("/users/:id", func(ctx *web.Context) { ctx.Text([]byte("hello")) })
+	app.RouteFunc("/fail", func(ctx *web.Context) { ctx.Error(http.StatusBadGateway) })
+	app.HealthCheck("/healthz")
+	return app
+}
+
+func TestAccessLogFormats(t *testing.T) {
+	var buf bytes.Buffer
+	serve(newApp(middleware.LogWriter(&buf)), "/users/1?a=b")
+	if line := buf.String(); !strings.Contains(line, `"GET /users/1?a=b HTTP/1.1" 200 5 "" "test-agent"`) {
+		t.Errorf("unexpected combined line %s", line)
+	}
+
+	buf.Reset()
+	serve(newApp(middleware.LogWriter(&buf), middleware.LogFormat(middleware.FormatJSON)), "/users/1")
+	var entry middleware.AccessEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Route != "/users/:id" || entry.RequestID != "req-1" || entry.Bytes != 5 || entry.UserAgent != "test-agent" {
+		t.Errorf("unexpected json entry %+v", entry)
+	}
+
+	buf.Reset()
+	serve(newApp(middleware.LogWriter(&buf), middleware.LogFormat("{{.Method}} {{.Route}} {{.Status}}")), "/users/1")
+	if line := buf.String(); line != "GET /users/:id 200\n" {
+		t.Errorf("unexpected template line %q", line)
+	}
+}
+
+func TestAccessLogSampleExclude(t *testing.T) {
+	var buf bytes.Buffer
+	app := newApp(middleware.LogWriter(&buf), middleware.LogSample(0), middleware.LogExclude("/healthz"),
+		middleware.LogFormat("{{.URI}} {{.Status}}"))
+	serve(app, "/users/1")
+	serve(app, "/healthz")
+	serve(app, "/fail")
+	if got := buf.String(); got != "/fail 502\n" {
+		t.Errorf("expect only the failed request logged, got %q", got)
+	}
+}
+
+func TestAccessLogPanic(t *testing.T) {
+	for name, mids := range map[string][]func(*web.Context){
+		"without Recovery": nil,
+		"Recovery inside":  {middleware.Recovery()},
+	} {
+		var buf bytes.Buffer
+		app := web.New(web.DisableAccessLog())
+		app.Use(middleware.AccessLog(middleware.LogWriter(&buf), middleware.LogSample(0), middleware.LogFormat("{{.URI}} {{.Status}}")))
+		app.Use(mids...)
+		app.RouteFunc("/panic", func(ctx *web.Context) { panic("boom") })
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+		if w.Code != http.StatusInternalServerError || buf.String() != "/panic 500\n" {
+			t.Errorf("%s: expect panic logged as 500, got %d %q", name, w.Code, buf.String())
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/corex-io/web"
+)
+
+// Encoder new a writer compressing to w in one content coding. Writers with Reset(io.Writer) are
+// pooled, and flushed on Context.Flush if they have Flush() error, like gzip.Writer.
+type Encoder func(w io.Writer) (io.WriteCloser, error)
+
+// DefaultCompressSkipTypes media types compressed already, a type ending with "/" matches all its
+// subtypes. image/svg+xml is compressed all the same.
+var DefaultCompressSkipTypes = []string{
+	"image/", "video/", "audio/", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/x-bzip2", "application/pdf",
+}
+
+// CompressOptions options of Compress
+type CompressOptions struct {
+	Level     int      // level of gzip and deflate, flate.DefaultCompression by default
+	MinLength int      // bytes below which responses are sent uncompressed, 1024 by default
+	SkipTypes []string // media types not compressed, DefaultCompressSkipTypes by default
+	encoders  []encoding
+}
+
+type encoding struct {
+	coding  string
+	new     Encoder
+	builtin bool // gzip or deflate, preferred less than encoders added
+}
+
+// CompressOption func
+type CompressOption func(*CompressOptions)
+
+// CompressLevel set the level of gzip and deflate
+func CompressLevel(level int) CompressOption {
+	return func(o *CompressOptions) {
+		o.Level = level
+	}
+}
+
+// CompressMinLength set bytes below which responses are sent uncompressed
+func CompressMinLength(n int) CompressOption {
+	return func(o *CompressOptions) {
+		o.MinLength = n
+	}
+}
+
+// CompressSkipTypes add media types not compressed
+func CompressSkipTypes(types ...string) CompressOption {
+	return func(o *CompressOptions) {
+		o.SkipTypes = append(o.SkipTypes, types...)
+	}
+}
+
+// CompressEncoder add an encoder of coding, preferred to gzip and deflate and to encoders added later
+// when the client accepts them equally, or replace the encoder of gzip or deflate. For brotli:
+//
+//	middleware.CompressEncoder("br", func(w io.Writer) (io.WriteCloser, error) {
+//		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+//	})
+func CompressEncoder(coding string, enc Encoder) CompressOption {
+	return func(o *CompressOptions) {
+		coding = strings.ToLower(coding)
+		for i := range o.encoders {
+			if o.encoders[i].coding == coding {
+				o.encoders[i].new = enc
+				return
+			}
+		}
+		o.encoders = append(o.encoders, encoding{coding: coding, new: enc})
+	}
+}
+
+// Compress compress responses in the coding negotiated by Accept-Encoding with q-values, gzip and
+// deflate built in, and set Vary: Accept-Encoding. Responses are sent as is if they are smaller than
+// MinLength, of a skipped media type, encoded already, partial or without body, or marked
+// no-transform. HEAD and Range requests are not compressed, so Context.Download and static files
+// keep serving byte ranges of the identity content. Flush sends the compressed bytes so far for
+// streaming responses. If the handler panics after compressing started, the connection is closed
+// rather than the response ended as complete, see Recovery for rendering earlier panics.
+func Compress(opts ...CompressOption) func(*web.Context) {
+	options := CompressOptions{
+		Level:     flate.DefaultCompression,
+		MinLength: 1024,
+		SkipTypes: append([]string(nil), DefaultCompressSkipTypes...),
+	}
+	// built in first, so CompressEncoder of the options may replace them
+	options.encoders = []encoding{
+		{"gzip", func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriterLevel(w, options.Level) }, true},
+		{"deflate", func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriterLevel(w, options.Level) }, true},
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	pools := make(map[string]*encoderPool, len(options.encoders))
+	offers := make([]string, 0, len(options.encoders)+1)
+	for _, builtin := range []bool{false, true} {
+		for _, e := range options.encoders {
+			if e.builtin == builtin {
+				pools[e.coding] = &encoderPool{coding: e.coding, new: e.new}
+				offers = append(offers, e.coding)
+			}
+		}
+	}
+	offers = append(offers, "identity")
+
+	return func(ctx *web.Context) {
+		web.AddVary(ctx.ResponseWriter.Header(), "Accept-Encoding")
+		if ctx.Request.Method == http.MethodHead || ctx.Request.Header.Get("Range") != "" {
+			ctx.Next()
+			return
+		}
+		pool := pools[web.NegotiateEncoding(ctx.Request.Header.Get("Accept-Encoding"), offers...)]
+		if pool == nil {
+			ctx.Next()
+			return
+		}
+		cw := &compressWriter{
+			ResponseWriter: ctx.ResponseWriter,
+			pool:           pool,
+			opts:           &options,
+		}
+		ctx.ResponseWriter = cw
+		served := false
+		defer func() {
+			ctx.ResponseWriter = cw.ResponseWriter
+			if !served {
+				cw.abort()
+			}
+		}()
+		ctx.Next()
+		served = true
+		cw.Close()
+	}
+}
+
+// encoderPool reuse encoders with Reset(io.Writer)
+type encoderPool struct {
+	coding string
+	new    Encoder
+	pool   sync.Pool
+}
+
+type resetter interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+func (p *encoderPool) get(w io.Writer) (io.WriteCloser, error) {
+	if enc, ok := p.pool.Get().(resetter); ok {
+		enc.Reset(w)
+		return enc, nil
+	}
+	return p.new(w)
+}
+
+func (p *encoderPool) put(enc io.WriteCloser) {
+	if _, ok := enc.(resetter); ok {
+		p.pool.Put(enc)
+	}
+}
+
+// compressWriter buffer the body up to MinLength before deciding to compress it, then write through
+// the encoder or as is
+type compressWriter struct {
+	http.ResponseWriter
+	pool     *encoderPool
+	opts     *CompressOptions
+	code     int
+	buf      []byte
+	started  bool
+	hijacked bool
+	encoder  io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.started || w.code != 0 {
+		return
+	}
+	if code < http.StatusOK && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.code = code
+	switch {
+	case !w.compressible():
+		_ = w.start(false)
+	case w.contentLength() >= int64(w.opts.MinLength):
+		_ = w.start(true)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.started {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.opts.MinLength {
+			return len(b), nil
+		}
+		w.sniff()
+		return len(b), w.start(w.compressible())
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush send the body so far, compressed if it may be, though shorter than MinLength, since a
+// streaming response continues
+func (w *compressWriter) Flush() {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.started {
+		w.sniff()
+		_ = w.start(w.compressible())
+	}
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack http.Hijacker, nothing is written after
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Push http.Pusher
+func (w *compressWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap return the underlying writer, used by http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close send the body buffered as is and finish the compressed stream
+func (w *compressWriter) Close() {
+	if w.hijacked {
+		return
+	}
+	if !w.started && w.code != 0 {
+		_ = w.start(false)
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+		w.pool.put(w.encoder)
+		w.encoder = nil
+	}
+}
+
+// abort end the response of a panicking handler. The body buffered is dropped, so Recovery renders
+// the error. A compressed body already started is cut off by closing the connection, so the client
+// doesn't take it as complete, or finished as a valid stream if the connection can't be hijacked.
+func (w *compressWriter) abort() {
+	if w.encoder == nil || w.hijacked {
+		return
+	}
+	if conn, _, err := w.Hijack(); err == nil {
+		_ = conn.Close()
+		return
+	}
+	_ = w.encoder.Close()
+	w.pool.put(w.encoder)
+	w.encoder = nil
+}
+
+// start send the header, and the body buffered through the encoder if compress
+func (w *compressWriter) start(compress bool) error {
+	w.started = true
+	header := w.ResponseWriter.Header()
+	if compress {
+		if enc, err := w.pool.get(w.ResponseWriter); err == nil {
+			w.encoder = enc
+			header.Set("Content-Encoding", w.pool.coding)
+			header.Del("Content-Length")
+			header.Del("Accept-Ranges")
+			// another representation than the identity one
+			if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// sniff set Content-Type from the body buffered as net/http does, which could not once compressed
+func (w *compressWriter) sniff() {
+	header := w.ResponseWriter.Header()
+	if _, ok := header["Content-Type"]; !ok && len(w.buf) != 0 && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+}
+
+// compressible return whether the response may be compressed by its status and header
+func (w *compressWriter) compressible() bool {
+	switch w.code {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent, http.StatusSwitchingProtocols:
+		return false
+	}
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" ||
+		strings.Contains(strings.ToLower(header.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+	if n := w.contentLength(); n >= 0 && n < int64(w.opts.MinLength) {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if mediaType == "image/svg+xml" {
+		return true
+	}
+	for _, skip := range w.opts.SkipTypes {
+		if mediaType == skip || strings.HasSuffix(skip, "/") && strings.HasPrefix(mediaType, skip) {
+			return false
+		}
+	}
+	return true
+}
+
+// contentLength return Content-Length set by the handler, -1 if not set
+func (w *compressWriter) contentLength() int64 {
+	n, err := strconv.ParseInt(w.ResponseWriter.Header().Get("Content-Length"), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,234 @@
+package middleware_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corex-io/web"
+	"github.com/corex-io/web/middleware"
+)
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("hello compression ", 200)
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	app := web.New(web.DisableAccessLog(), web.TreeRouting())
+	app.Use(middleware.Recovery(), middleware.Compress(middleware.CompressEncoder("br", func(w io.Writer) (io.WriteCloser, error) {
+		// not brotli, marks the pluggable encoder is used
+		return gzip.NewWriterLevel(w, gzip.BestSpeed)
+	})))
+	app.RouteFunc("/text", func(ctx *web.Context) { ctx.Text([]byte(body)) })
+	app.RouteFunc("/small", func(ctx *web.Context) { ctx.Text([]byte("tiny")) })
+	app.RouteFunc("/png", func(ctx *web.Context) {
+		ctx.ResponseWriter.Header().Set("Content-Type", "image/png")
+		_, _ = ctx.ResponseWriter.Write([]byte(body))
+	})
+	app.RouteFunc("/sniff", func(ctx *web.Context) {
+		_, _ = ctx.ResponseWriter.Write([]byte("<html>" + body))
+	})
+	app.RouteFunc("/download", func(ctx *web.Context) {
+		ctx.DownloadContent("report.txt", modtime, strings.NewReader(body))
+	})
+	app.RouteFunc("/panic", func(ctx *web.Context) {
+		_, _ = ctx.ResponseWriter.Write([]byte("partial"))
+		panic("boom")
+	})
+	app.RouteFunc("/panic-late", func(ctx *web.Context) {
+		_, _ = ctx.ResponseWriter.Write([]byte(body))
+		panic("boom")
+	})
+
+	serve := func(method, path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+	gunzip := func(b []byte) string {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(s)
+	}
+
+	w := serve(http.MethodGet, "/text", map[string]string{"Accept-Encoding": "gzip, deflate"})
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" ||
+		w.Header().Get("Content-Length") != "" || w.Body.Len() >= len(body) || gunzip(w.Body.Bytes()) != body {
+		t.Errorf("unexpected gzip response %v %d bytes", w.Header(), w.Body.Len())
+	}
+
+	w = serve(http.MethodGet, "/text", map[string]string{"Accept-Encoding": "gzip;q=0.5, deflate"})
+	if w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("expect deflate, got %v", w.Header())
+	}
+	if r, err := zlib.NewReader(w.Body); err != nil {
+		t.Error(err)
+	} else if b, _ := io.ReadAll(r); string(b) != body {
+		t.Errorf("unexpected deflate body %q", b)
+	}
+
+	if w := serve(http.MethodGet, "/text", map[string]string{"Accept-Encoding": "gzip, br"}); w.Header().Get("Content-Encoding") != "br" {
+		t.Errorf("expect pluggable br preferred, got %v", w.Header())
+	}
+	for _, accept := range []string{"", "identity", "gzip;q=0, deflate;q=0", "compress"} {
+		if w := serve(http.MethodGet, "/text", map[string]string{"Accept-Encoding": accept}); w.Header().Get("Content-Encoding") != "" ||
+			w.Body.String() != body || w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Accept-Encoding %q: expect identity, got %v", accept, w.Header())
+		}
+	}
+
+	for _, path := range []string{"/small", "/png"} {
+		if w := serve(http.MethodGet, path, map[string]string{"Accept-Encoding": "gzip"}); w.Header().Get("Content-Encoding") != "" ||
+			w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: expect uncompressed, got %v", path, w.Header())
+		}
+	}
+	if w := serve(http.MethodGet, "/sniff", map[string]string{"Accept-Encoding": "gzip"}); w.Header().Get("Content-Encoding") != "gzip" ||
+		w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("expect sniffed html compressed, got %v", w.Header())
+	}
+	if w := serve(http.MethodHead, "/text", map[string]string{"Accept-Encoding": "gzip"}); w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("expect HEAD uncompressed, got %v", w.Header())
+	}
+
+	w = serve(http.MethodGet, "/download", map[string]string{"Accept-Encoding": "gzip"})
+	etag := w.Header().Get("ETag")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Accept-Ranges") != "" || !strings.HasPrefix(etag, "W/") ||
+		gunzip(w.Body.Bytes()) != body {
+		t.Errorf("unexpected compressed download %v", w.Header())
+	}
+	if w := serve(http.MethodGet, "/download", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Errorf("expect 304 for weak etag, got %d", w.Code)
+	}
+	w = serve(http.MethodGet, "/download", map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-4"})
+	if w.Code != http.StatusPartialContent || w.Header().Get("Content-Encoding") != "" || w.Body.String() != "hello" {
+		t.Errorf("unexpected range download %d %v %q", w.Code, w.Header(), w.Body)
+	}
+
+	w = serve(http.MethodGet, "/panic", map[string]string{"Accept-Encoding": "gzip"})
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Encoding") != "" || strings.Contains(w.Body.String(), "partial") {
+		t.Errorf("unexpected panic response %d %v %q", w.Code, w.Header(), w.Body)
+	}
+	// compression started, the recorder can't be hijacked so the stream is finished as is
+	w = serve(http.MethodGet, "/panic-late", map[string]string{"Accept-Encoding": "gzip"})
+	if w.Header().Get("Content-Encoding") != "gzip" || gunzip(w.Body.Bytes()) != body {
+		t.Errorf("unexpected late panic response %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCompressOptions(t *testing.T) {
+	body := strings.Repeat("hello compression ", 300)
+	var custom int
+	app := web.New(web.DisableAccessLog())
+	app.Use(middleware.Compress(middleware.CompressSkipTypes("text/csv"), middleware.CompressEncoder("gzip", func(w io.Writer) (io.WriteCloser, error) {
+		custom++
+		return gzip.NewWriterLevel(w, gzip.BestSpeed)
+	})))
+	for path, contentType := range map[string]string{"/png": "image/png", "/csv": "text/csv", "/text": "text/plain"} {
+		contentType := contentType
+		app.RouteFunc(path, func(ctx *web.Context) {
+			ctx.ResponseWriter.Header().Set("Content-Type", contentType)
+			_, _ = ctx.ResponseWriter.Write([]byte(body))
+		})
+	}
+
+	for path, coding := range map[string]string{"/png": "", "/csv": "", "/text": "gzip"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		if w.Header().Get("Content-Encoding") != coding {
+			t.Errorf("%s: expect coding %q, got %v", path, coding, w.Header())
+		}
+	}
+	if custom != 1 {
+		t.Errorf("expect the gzip encoder replaced, called %d times", custom)
+	}
+}
+
+func TestCompressPanic(t *testing.T) {
+	app := web.New(web.DisableAccessLog())
+	app.Use(middleware.Recovery(), middleware.Compress())
+	app.RouteFunc("/panic", func(ctx *web.Context) {
+		_, _ = ctx.ResponseWriter.Write([]byte(strings.Repeat("hello compression ", 200)))
+		panic("boom")
+	})
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/panic", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := (&http.Client{Transport: &http.Transport{DisableCompression: true}}).Do(req)
+	if err != nil {
+		// connection closed before the header was read
+		return
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Errorf("expect the response cut off after a panic, got %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	release := make(chan struct{})
+	app := web.New(web.DisableAccessLog())
+	app.Use(middleware.Compress())
+	app.RouteFunc("/events", func(ctx *web.Context) {
+		ctx.ResponseWriter.Header().Set("Content-Type", "text/event-stream")
+		_, _ = ctx.ResponseWriter.Write([]byte("data: 1\n\n"))
+		ctx.ResponseWriter.(http.Flusher).Flush()
+		<-release
+		_, _ = ctx.ResponseWriter.Write([]byte("data: 2\n\n"))
+	})
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := (&http.Client{Transport: &http.Transport{DisableCompression: true}}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expect gzip stream, got %v", resp.Header)
+	}
+
+	first := make(chan string, 1)
+	r, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		b := make([]byte, len("data: 1\n\n"))
+		_, _ = io.ReadFull(r, b)
+		first <- string(b)
+	}()
+	select {
+	case s := <-first:
+		if s != "data: 1\n\n" {
+			t.Errorf("unexpected first event %q", s)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("flushed event not received")
+	}
+	close(release)
+	if rest, _ := io.ReadAll(r); string(rest) != "data: 2\n\n" {
+		t.Errorf("unexpected rest %q", rest)
+	}
+}
@@ -1,8 +1,6 @@
 package middleware
 
 import (
-	"time"
-
 	"github.com/corex-io/web"
 )
 
@@ -13,15 +11,51 @@ func AppendHeader(key, value string) func(*web.Context) {
 	}
 }
 
-// Trace trace
-func Trace() func(*web.Context) {
+// Trace propagate W3C trace context: continue the trace of a valid traceparent header or begin
+// a new one with random ids, store it on Context and echo traceparent and the legacy request id
+// header (trace-id by default) in response. A legacy id given by the caller is kept as request id if
+// valid, see validRequestID, otherwise the trace id is used.
+func Trace(header ...string) func(*web.Context) {
+	legacy := "trace-id"
+	if len(header) != 0 {
+		legacy = header[0]
+	}
 	return func(ctx *web.Context) {
-		traceID := ctx.Request.Header.Get("trace-id")
-		if traceID == "" {
-			traceID = time.Now().String()
+		trace, err := web.ParseTraceparent(ctx.Request.Header.Get(web.HeaderTraceparent))
+		if err == nil {
+			trace.State = ctx.Request.Header.Get(web.HeaderTracestate)
+		} else {
+			trace = web.Trace{TraceID: web.NewTraceID(), Flags: 1}
+		}
+		trace.SpanID = web.NewSpanID()
+		trace.RequestID = ctx.Request.Header.Get(legacy)
+		if !validRequestID(trace.RequestID) {
+			trace.RequestID = trace.TraceID
+		}
+		ctx.SetTrace(trace)
+
+		ctx.Request.Header.Set(legacy, trace.RequestID)
+		ctx.ResponseWriter.Header().Set(legacy, trace.RequestID)
+		ctx.ResponseWriter.Header().Set(web.HeaderTraceparent, trace.Traceparent())
+	}
+}
+
+// maxRequestIDLen longest legacy request id kept from the caller
+const maxRequestIDLen = 128
+
+// validRequestID report whether id is short and made of letters, digits and -_.:, safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
 		}
-		uuid := time.Now().String()
-		ctx.Request.Header.Add("trace-id", uuid)
-		ctx.ResponseWriter.Header().Add("trace-id", uuid)
 	}
+	return true
 }
@@ -0,0 +1,112 @@
+package middleware_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/corex-io/web"
+	"github.com/corex-io/web/middleware"
+)
+
+func TestTrace(t *testing.T) {
+	var outbound http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound = r.Header
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: web.TraceTransport(nil, "X-Request-Id")}
+
+	var trace web.Trace
+	app := web.New(web.DisableAccessLog())
+	app.Use(middleware.Trace("X-Request-Id"))
+	app.RouteFunc("/", func(ctx *web.Context) {
+		trace = ctx.Trace()
+		req, _ := http.NewRequestWithContext(ctx.Request.Context(), http.MethodGet, upstream.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	})
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", parent)
+	req.Header.Set("tracestate", "congo=t61rcWkgMzE")
+	req.Header.Set("X-Request-Id", "legacy-1")
+	app.ServeHTTP(w, req)
+
+	if trace.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || trace.ParentID != "00f067aa0ba902b7" || trace.RequestID != "legacy-1" {
+		t.Errorf("trace not continued: %+v", trace)
+	}
+	if len(trace.SpanID) != 16 || trace.SpanID == trace.ParentID {
+		t.Errorf("expect new span id, got %s", trace.SpanID)
+	}
+	if got := w.Header().Get("traceparent"); got != trace.Traceparent() || w.Header().Get("X-Request-Id") != "legacy-1" {
+		t.Errorf("unexpected response headers %v", w.Header())
+	}
+	if outbound.Get("traceparent") != trace.Traceparent() || outbound.Get("tracestate") != "congo=t61rcWkgMzE" || outbound.Get("X-Request-Id") != "legacy-1" {
+		t.Errorf("trace not propagated: %v", outbound)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	app.ServeHTTP(w, req)
+	if len(trace.TraceID) != 32 || strings.Trim(trace.TraceID, "0") == "" || trace.ParentID != "" || trace.RequestID != trace.TraceID {
+		t.Errorf("expect new trace for invalid traceparent, got %+v", trace)
+	}
+	first := trace.TraceID
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if trace.TraceID == first {
+		t.Errorf("trace ids not unique")
+	}
+}
+
+type lineLogger struct {
+	lines []string
+}
+
+func (l *lineLogger) Debugf(format string, v ...interface{}) { l.add(format, v...) }
+func (l *lineLogger) Infof(format string, v ...interface{})  { l.add(format, v...) }
+func (l *lineLogger) Warnf(format string, v ...interface{})  { l.add(format, v...) }
+func (l *lineLogger) Errorf(format string, v ...interface{}) { l.add(format, v...) }
+
+func (l *lineLogger) add(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestTraceRequestID(t *testing.T) {
+	logger := &lineLogger{}
+	app := web.New()
+	app.SetLog(logger)
+	app.Use(middleware.Trace("X-Request-Id"))
+	app.RouteFunc("/", func(ctx *web.Context) {})
+
+	for id, keep := range map[string]bool{
+		"req-1":                                true,
+		"3f2c1a9e-7b2d-4c1e-9a0f-5d6b7c8d9e0f": true,
+		"a\r\nb":                               false,
+		"[admin] x":                            false,
+		"%s%n":                                 false,
+		strings.Repeat("a", 129):               false,
+	} {
+		logger.lines = nil
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header["X-Request-Id"] = []string{id}
+		app.ServeHTTP(w, req)
+
+		got := w.Header().Get("X-Request-Id")
+		if keep && got != id || !keep && (got == id || len(got) != 32) {
+			t.Errorf("request id %q: unexpected response id %q", id, got)
+		}
+		if len(logger.lines) != 1 || !strings.HasPrefix(logger.lines[0], "["+got+"] GET 200 /") {
+			t.Errorf("request id %q: expect access log prefixed by %q, got %q", id, got, logger.lines)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/corex-io/web"
+)
+
+// RecoveryOptions options of Recovery
+type RecoveryOptions struct {
+	Handler   func(ctx *web.Context, v interface{}, stack []byte) // render the error response instead of the default
+	Reporters []func(ctx *web.Context, v interface{}, stack []byte)
+	HTML      string // error page for clients accepting text/html
+	Repanic   bool   // panic again after reporting, for tests
+}
+
+// RecoveryOption func
+type RecoveryOption func(*RecoveryOptions)
+
+// RecoveryHandler render the error response by h, which is not called if the response already started
+func RecoveryHandler(h func(ctx *web.Context, v interface{}, stack []byte)) RecoveryOption {
+	return func(o *RecoveryOptions) {
+		o.Handler = h
+	}
+}
+
+// RecoveryReporter report every panic to f, like an error tracking service
+func RecoveryReporter(f func(ctx *web.Context, v interface{}, stack []byte)) RecoveryOption {
+	return func(o *RecoveryOptions) {
+		o.Reporters = append(o.Reporters, f)
+	}
+}
+
+// RecoveryHTML set the error page for clients accepting text/html
+func RecoveryHTML(page string) RecoveryOption {
+	return func(o *RecoveryOptions) {
+		o.HTML = page
+	}
+}
+
+// RecoveryRepanic panic again after reporting, so tests fail on panic
+func RecoveryRepanic() RecoveryOption {
+	return func(o *RecoveryOptions) {
+		o.Repanic = true
+	}
+}
+
+const defaultErrorPage = `<!DOCTYPE html>
+<html><head><title>500 Internal Server Error</title></head>
+<body><h1>500 Internal Server Error</h1></body></html>
+`
+
+// Recovery recover panics of the rest of the chain, including Route handlers and wrapped http.Handlers.
+// The panic is logged with stack and reported, then a 500 response is rendered as json or html by
+// the quality values of Accept, unless the response already started. http.ErrAbortHandler is passed through.
+func Recovery(opts ...RecoveryOption) func(*web.Context) {
+	options := RecoveryOptions{HTML: defaultErrorPage}
+	for _, o := range opts {
+		o(&options)
+	}
+	return func(ctx *web.Context) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			stack := debug.Stack()
+			ctx.SetPanic(v)
+			ctx.Abort()
+			ctx.Logger.Errorf("panic: %v\n%s", v, stack)
+			for _, report := range options.Reporters {
+				report(ctx, v, stack)
+			}
+			if options.Repanic {
+				panic(v)
+			}
+			if ctx.Written() || ctx.Hijacked() {
+				return
+			}
+			if options.Handler != nil {
+				options.Handler(ctx, v, stack)
+				return
+			}
+			renderPanic(ctx, options.HTML)
+		}()
+		ctx.Next()
+	}
+}
+
+// renderPanic respond json or html by the quality values of Accept, json if both are matched by the
+// same wildcard, plain text if Accept is absent or accepts neither
+func renderPanic(ctx *web.Context, page string) {
+	if ctx.Request.Header.Get("Accept") == "" {
+		ctx.Error(http.StatusInternalServerError)
+		return
+	}
+	switch web.NegotiateType(ctx.Request.Header.Values("Accept"), "application/json", "text/html") {
+	case "application/json":
+		ctx.JSONStatus(http.StatusInternalServerError, nil, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+	case "text/html":
+		ctx.SetStatusCode(http.StatusInternalServerError)
+		ctx.ResponseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+		ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		_, _ = ctx.ResponseWriter.Write([]byte(page))
+	default:
+		ctx.Error(http.StatusInternalServerError)
+	}
+}
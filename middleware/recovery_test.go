@@ -0,0 +1,79 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/corex-io/web"
+	"github.com/corex-io/web/middleware"
+)
+
+func TestRecovery(t *testing.T) {
+	var reported []interface{}
+	app := web.New(web.DisableAccessLog())
+	app.Use(middleware.Recovery(middleware.RecoveryReporter(func(ctx *web.Context, v interface{}, stack []byte) {
+		if len(stack) == 0 {
+			t.Errorf("expect stack")
+		}
+		reported = append(reported, v)
+	})))
+	app.RouteFunc("/panic", func(ctx *web.Context) { panic("boom") })
+	app.HandleFunc("/started", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("late")
+	})
+
+	cases := []struct {
+		path, accept string
+		code         int
+		contentType  string
+		body         string
+	}{
+		{"/panic", "application/json", http.StatusInternalServerError, "application/json", `"code":500`},
+		{"/panic", "text/html,*/*", http.StatusInternalServerError, "text/html", "<h1>500"},
+		{"/panic", "", http.StatusInternalServerError, "text/plain", "Internal Server Error"},
+		{"/panic", "application/json;q=0, text/html", http.StatusInternalServerError, "text/html", "<h1>500"},
+		{"/panic", "application/json;q=0.5, text/html;q=0.8", http.StatusInternalServerError, "text/html", "<h1>500"},
+		{"/panic", "application/json;q=0", http.StatusInternalServerError, "text/plain", "Internal Server Error"},
+		{"/started", "application/json", http.StatusAccepted, "", "partial"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Header.Set("Accept", c.accept)
+		app.ServeHTTP(w, req)
+		if w.Code != c.code || !strings.HasPrefix(w.Header().Get("Content-Type"), c.contentType) || !strings.Contains(w.Body.String(), c.body) {
+			t.Errorf("%s %s: unexpected %d %s %s", c.path, c.accept, w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+	}
+	if len(reported) != len(cases) {
+		t.Errorf("expect %d reports, got %v", len(cases), reported)
+	}
+}
+
+func TestRecoveryHandlerRepanic(t *testing.T) {
+	app := web.New(web.DisableAccessLog())
+	app.Use(middleware.Recovery(middleware.RecoveryHandler(func(ctx *web.Context, v interface{}, stack []byte) {
+		ctx.Error(http.StatusServiceUnavailable)
+	})))
+	app.RouteFunc("/panic", func(ctx *web.Context) { panic("boom") })
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect custom handler 503, got %d", w.Code)
+	}
+
+	repanic := middleware.Recovery(middleware.RecoveryRepanic())
+	defer func() {
+		if recover() != "boom" {
+			t.Errorf("expect repanic")
+		}
+	}()
+	app = web.New(web.DisableAccessLog())
+	app.Use(repanic)
+	app.RouteFunc("/panic", func(ctx *web.Context) { panic("boom") })
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+}
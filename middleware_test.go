@@ -0,0 +1,60 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/corex-io/web"
+)
+
+func TestMiddlewareNext(t *testing.T) {
+	var trace []string
+	app := web.New()
+	app.Use(func(ctx *web.Context) {
+		trace = append(trace, "outer before")
+		ctx.Next()
+		trace = append(trace, "outer after")
+	})
+	app.Use(func(ctx *web.Context) {
+		trace = append(trace, "legacy")
+	})
+	app.Use(web.WrapMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Wrapped", "1")
+			if r.URL.Path == "/blocked" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}))
+	app.Group("/g", func(ctx *web.Context) {
+		trace = append(trace, "group before")
+		ctx.Next()
+		trace = append(trace, "group after")
+	}).RouteFunc("/h", func(ctx *web.Context) {
+		trace = append(trace, "handler")
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/g/h", nil))
+	expect := "outer before,legacy,group before,handler,group after,outer after"
+	if got := strings.Join(trace, ","); got != expect {
+		t.Errorf("expect %s, got %s", expect, got)
+	}
+	if w.Header().Get("X-Wrapped") != "1" {
+		t.Errorf("wrapped middleware not run")
+	}
+
+	trace = nil
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/blocked", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expect 401, got %d", w.Code)
+	}
+	if expect := "outer before,legacy,outer after"; strings.Join(trace, ",") != expect {
+		t.Errorf("expect %s, got %s", expect, strings.Join(trace, ","))
+	}
+}
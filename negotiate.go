@@ -0,0 +1,317 @@
+package web
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// RenderFunc encode v into w in one format
+type RenderFunc func(w io.Writer, v interface{}) error
+
+type renderer struct {
+	mediaType   string // type/subtype matched against Accept
+	contentType string // Content-Type of the response
+	render      RenderFunc
+}
+
+// JSONRenderer encode v as json
+func JSONRenderer(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// XMLRenderer encode v as xml with the xml header
+func XMLRenderer(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// CSVRenderer encode [][]string, []string, or a slice of structs with a header row of
+// the `csv` tags or field names
+func CSVRenderer(w io.Writer, v interface{}) error {
+	cw := csv.NewWriter(w)
+	switch rows := v.(type) {
+	case [][]string:
+		return writeCSV(cw, rows)
+	case []string:
+		return writeCSV(cw, [][]string{rows})
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Errorf("csv: unsupported type %T", v)
+	}
+	typ := rv.Type().Elem()
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("csv: unsupported type %T", v)
+	}
+	var header []string
+	var fields []int
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("csv") == "-" {
+			continue
+		}
+		name := tagName(f, "csv")
+		if name == "" {
+			name = f.Name
+		}
+		header = append(header, name)
+		fields = append(fields, i)
+	}
+	rows := [][]string{header}
+	for i := 0; i < rv.Len(); i++ {
+		elem := reflect.Indirect(rv.Index(i))
+		row := make([]string, len(fields))
+		if elem.IsValid() {
+			for j, field := range fields {
+				row[j] = fmt.Sprint(elem.Field(field).Interface())
+			}
+		}
+		rows = append(rows, row)
+	}
+	return writeCSV(cw, rows)
+}
+
+func writeCSV(cw *csv.Writer, rows [][]string) error {
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// defaultRenderers renderers of Web in server preference order
+func defaultRenderers() []renderer {
+	var rs []renderer
+	for _, r := range []struct {
+		contentType string
+		render      RenderFunc
+	}{
+		{"application/json; charset=utf-8", JSONRenderer},
+		{"application/xml; charset=utf-8", XMLRenderer},
+		{"text/xml; charset=utf-8", XMLRenderer},
+		{"application/yaml; charset=utf-8", YAMLRenderer},
+		{"application/x-yaml; charset=utf-8", YAMLRenderer},
+		{"text/yaml; charset=utf-8", YAMLRenderer},
+		{"application/msgpack", MsgpackRenderer},
+		{"application/x-msgpack", MsgpackRenderer},
+		{"application/x-protobuf", ProtobufRenderer},
+		{"application/protobuf", ProtobufRenderer},
+		{"text/csv; charset=utf-8", CSVRenderer},
+	} {
+		rs = addRenderer(rs, r.contentType, r.render)
+	}
+	return rs
+}
+
+func addRenderer(rs []renderer, contentType string, f RenderFunc) []renderer {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		panic(fmt.Sprintf("web: invalid content type %q: %v", contentType, err))
+	}
+	for i := range rs {
+		if rs[i].mediaType == mediaType {
+			rs[i].contentType, rs[i].render = contentType, f
+			return rs
+		}
+	}
+	return append(rs, renderer{mediaType: mediaType, contentType: contentType, render: f})
+}
+
+// AddRenderer register f for Context.Negotiate to respond contentType, replacing the renderer of the
+// same media type. A renderer added later is preferred less when the client accepts both equally, e.g.
+//
+//	app.AddRenderer("application/cbor", func(w io.Writer, v interface{}) error {
+//		return cbor.NewEncoder(w).Encode(v)
+//	})
+func (s *Web) AddRenderer(contentType string, f RenderFunc) {
+	s.renderers = addRenderer(s.renderers, contentType, f)
+}
+
+// Negotiate respond data with status 200 in the format the Accept header prefers, see NegotiateStatus
+func (ctx *Context) Negotiate(data interface{}) {
+	ctx.NegotiateStatus(http.StatusOK, data)
+}
+
+// NegotiateStatus respond data with status in the format the Accept header prefers by quality values,
+// then by the most specific range, the first registered format if Accept is empty, or 406 if no format
+// is acceptable
+func (ctx *Context) NegotiateStatus(status int, data interface{}) {
+	AddVary(ctx.ResponseWriter.Header(), "Accept")
+	renderers := defaultRenderers()
+	if ctx.web != nil {
+		renderers = ctx.web.renderers
+	}
+	r := negotiate(ctx.Request.Header.Values("Accept"), renderers)
+	if r == nil {
+		ctx.HandleError(NewHTTPError(http.StatusNotAcceptable, "no acceptable format"))
+		return
+	}
+	var buf bytes.Buffer
+	if err := r.render(&buf, data); err != nil {
+		ctx.HandleError(fmt.Errorf("render %s: %w", r.mediaType, err))
+		return
+	}
+	ctx.SetStatusCode(status)
+	ctx.ResponseWriter.Header().Set("Content-Type", r.contentType)
+	ctx.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	ctx.ResponseWriter.WriteHeader(status)
+	_, _ = buf.WriteTo(ctx.ResponseWriter)
+}
+
+// acceptRange one media range of the Accept header
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parse Accept headers, ranges with invalid quality are skipped
+func parseAccept(values []string) []acceptRange {
+	var ranges []acceptRange
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			params := strings.Split(part, ";")
+			typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+			if !ok || typ == "" || subtype == "" {
+				continue
+			}
+			ar := acceptRange{typ: typ, subtype: subtype, q: 1}
+			for _, param := range params[1:] {
+				k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(k, "q") {
+					q, err := strconv.ParseFloat(v, 64)
+					if err != nil || q < 0 || q > 1 {
+						q = -1
+					}
+					ar.q = q
+				}
+			}
+			if ar.q >= 0 {
+				ranges = append(ranges, ar)
+			}
+		}
+	}
+	return ranges
+}
+
+// match return the quality and specificity of the most specific range matching mediaType, 2 for
+// type/subtype, 1 for type/*, 0 for */*, -1 if none
+func match(ranges []acceptRange, mediaType string) (float64, int) {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, ar := range ranges {
+		s := -1
+		switch {
+		case ar.typ == typ && ar.subtype == subtype:
+			s = 2
+		case ar.typ == typ && ar.subtype == "*":
+			s = 1
+		case ar.typ == "*" && ar.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+	return q, specificity
+}
+
+// negotiate return the renderer of the media type NegotiateType prefers
+func negotiate(accept []string, renderers []renderer) *renderer {
+	offers := make([]string, len(renderers))
+	for i := range renderers {
+		offers[i] = renderers[i].mediaType
+	}
+	mediaType := NegotiateType(accept, offers...)
+	for i := range renderers {
+		if renderers[i].mediaType == mediaType {
+			return &renderers[i]
+		}
+	}
+	return nil
+}
+
+// NegotiateType return the media type of offers the Accept headers prefer by quality values, ties go to
+// the offer matched by the more specific range, then the earlier offer, so "text/html, */*" prefers
+// text/html. The first offer if Accept is empty, empty if no offer is acceptable.
+func NegotiateType(accept []string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return offers[0]
+	}
+	best, bestQ, bestSpecificity := "", 0.0, -1
+	for _, offer := range offers {
+		q, specificity := match(ranges, strings.ToLower(offer))
+		if q > bestQ || q == bestQ && q > 0 && specificity > bestSpecificity {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+	return best
+}
+
+// AddVary add field to the Vary header unless already listed
+func AddVary(header http.Header, field string) {
+	for _, value := range header.Values("Vary") {
+		for _, f := range strings.Split(value, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", field)
+}
+
+// NegotiateEncoding return the content coding of offers the Accept-Encoding header prefers by quality
+// values, ties go to the earlier offer. "identity" is acceptable unless refused, preferred least if not
+// listed, other codings only if listed or matched by "*". Empty if no offer is acceptable.
+func NegotiateEncoding(acceptEncoding string, offers ...string) string {
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(k, "q") {
+				var err error
+				if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+			}
+		}
+		qs[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, ok := qs[strings.ToLower(offer)]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if !ok && strings.EqualFold(offer, "identity") {
+			q = 0.001
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
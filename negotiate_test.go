@@ -0,0 +1,102 @@
+package web_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/corex-io/web"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type negotiateUser struct {
+	Name string `json:"name" xml:"name" yaml:"name" csv:"user"`
+	Age  int    `json:"age" xml:"age" yaml:"age"`
+	Note string `json:"note,omitempty" xml:"-" yaml:"note,omitempty" csv:"-"`
+}
+
+func TestNegotiate(t *testing.T) {
+	app := web.New(web.DisableAccessLog(), web.TreeRouting())
+	app.RouteFunc("/user", func(ctx *web.Context) {
+		ctx.Negotiate(negotiateUser{Name: "bob", Age: 30, Note: "yes: no"})
+	})
+	app.RouteFunc("/users", func(ctx *web.Context) {
+		ctx.Negotiate([]negotiateUser{{Name: "bob", Age: 30}, {Name: "amy", Age: 7}})
+	})
+	app.RouteFunc("/message", func(ctx *web.Context) {
+		ctx.Negotiate(wrapperspb.Int32(7))
+	})
+	app.AddRenderer("text/plain; charset=utf-8", func(w io.Writer, v interface{}) error {
+		_, err := io.WriteString(w, "plain")
+		return err
+	})
+
+	serve := func(path, accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, c := range []struct {
+		path, accept, contentType, body string
+	}{
+		{"/user", "", "application/json; charset=utf-8", `{"name":"bob","age":30,"note":"yes: no"}` + "\n"},
+		{"/user", "application/json;q=0.5, application/xml", "application/xml; charset=utf-8", `<?xml version="1.0" encoding="UTF-8"?>` + "\n<negotiateUser><name>bob</name><age>30</age></negotiateUser>"},
+		{"/user", "text/*;q=0.3, application/yaml;q=0.9, */*;q=0.1", "application/yaml; charset=utf-8", "name: bob\nage: 30\nnote: 'yes: no'\n"},
+		{"/user", "application/msgpack", "application/msgpack", "\x83\xa4name\xa3bob\xa3age\x1e\xa4note\xa7yes: no"},
+		{"/message", "application/x-protobuf", "application/x-protobuf", "\x08\x07"},
+		{"/message", "application/protobuf", "application/protobuf", "\x08\x07"},
+		{"/users", "text/csv", "text/csv; charset=utf-8", "user,Age\nbob,30\namy,7\n"},
+		{"/users", "text/plain, */*;q=0.8", "text/plain; charset=utf-8", "plain"},
+		{"/users", "application/json;q=0, */*", "application/xml; charset=utf-8", ""},
+		{"/user", "application/xml, */*", "application/xml; charset=utf-8", ""},
+		{"/users", "text/csv, */*", "text/csv; charset=utf-8", ""},
+		{"/users", "text/*, application/json;q=0.9", "text/xml; charset=utf-8", ""},
+	} {
+		w := serve(c.path, c.accept)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != c.contentType || w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s %q: unexpected response %d %v", c.path, c.accept, w.Code, w.Header())
+		}
+		if c.body != "" && w.Body.String() != c.body {
+			t.Errorf("%s %q: unexpected body %q", c.path, c.accept, w.Body)
+		}
+	}
+
+	w := serve("/user", "image/png, application/json;q=0")
+	if w.Code != http.StatusNotAcceptable || w.Header().Get("Vary") != "Accept" {
+		t.Errorf("expect 406 with Vary, got %d %v", w.Code, w.Header())
+	}
+	if w := serve("/user", "text/csv"); w.Code != http.StatusInternalServerError || bytes.Contains(w.Body.Bytes(), []byte("bob")) {
+		t.Errorf("expect 500 for unrenderable data, got %d %s", w.Code, w.Body)
+	}
+	if w := serve("/user", "application/x-protobuf"); w.Code != http.StatusInternalServerError || bytes.Contains(w.Body.Bytes(), []byte("bob")) {
+		t.Errorf("expect 500 for data without Marshal, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestNegotiateType(t *testing.T) {
+	for _, c := range []struct {
+		accept []string
+		offers []string
+		expect string
+	}{
+		{nil, []string{"application/json", "text/html"}, "application/json"},
+		{[]string{"text/html,*/*;q=0.8"}, []string{"application/json", "text/html"}, "text/html"},
+		{[]string{"*/*"}, []string{"application/json", "text/html"}, "application/json"},
+		{[]string{"text/html, */*"}, []string{"application/json", "text/html"}, "text/html"},
+		{[]string{"application/json;q=0, text/*"}, []string{"application/json", "text/html"}, "text/html"},
+		{[]string{"application/json;q=0"}, []string{"application/json", "text/html"}, ""},
+		{[]string{"text/plain", "application/JSON;q=0.5"}, []string{"application/json", "text/html"}, "application/json"},
+		{[]string{"*/*"}, nil, ""},
+	} {
+		if got := web.NegotiateType(c.accept, c.offers...); got != c.expect {
+			t.Errorf("NegotiateType(%q, %v) = %q, expect %q", c.accept, c.offers, got, c.expect)
+		}
+	}
+}
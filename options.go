@@ -4,15 +4,23 @@ import "strings"
 
 // Options options
 type Options struct {
-	Address           string `yaml:"address" json:"address,omitempty"`
-	CertFile          string
-	KeyFile           string
-	ReadTimeout       int
-	ReadHeaderTimeout int
-	WriteTimeout      int
-	IdleTimeout       int
-	MaxHeaderBytes    int
-	StaticPaths       map[string]string //静态文件路径头 strings.Trim(path, staticPath)
+	Address            string `yaml:"address" json:"address,omitempty"`
+	CertFile           string
+	KeyFile            string
+	ReadTimeout        int
+	ReadHeaderTimeout  int
+	WriteTimeout       int
+	IdleTimeout        int
+	MaxHeaderBytes     int
+	ShutdownDelay      int               // seconds to wait after readiness turns unhealthy before draining
+	DrainTimeout       int               // seconds to wait for in-flight requests on shutdown
+	CertReloadInterval int               // seconds between checks of certificate files for change, 0 no check
+	ReloadOnSIGHUP     bool              // reload certificates on SIGHUP, off so the process keeps its own handling
+	RequestTimeout     int               // seconds to serve a route before responding 503, 0 no timeout
+	DisableAccessLog   bool              // turn off the built-in access log, e.g. replaced by middleware.AccessLog
+	TreeRouting        bool              // match plain paths like /users exactly by the radix tree, not as unanchored regexes
+	Listeners          []Listener        `yaml:"listeners" json:"listeners,omitempty"` // serve these instead of Address if not empty
+	StaticPaths        map[string]string //静态文件路径头 strings.Trim(path, staticPath)
 }
 
 // Option func
@@ -20,8 +28,9 @@ type Option func(*Options)
 
 func newOptions(opts ...Option) Options {
 	opt := Options{
-		Address:     "127.0.0.1:8080",
-		StaticPaths: map[string]string{},
+		Address:      "127.0.0.1:8080",
+		DrainTimeout: 30,
+		StaticPaths:  map[string]string{},
 	}
 
 	for _, o := range opts {
@@ -38,6 +47,57 @@ func Address(addr string) Option {
 	}
 }
 
+// ShutdownDelay seconds to wait after readiness turns unhealthy before draining
+func ShutdownDelay(seconds int) Option {
+	return func(o *Options) {
+		o.ShutdownDelay = seconds
+	}
+}
+
+// DrainTimeout seconds to wait for in-flight requests on shutdown
+func DrainTimeout(seconds int) Option {
+	return func(o *Options) {
+		o.DrainTimeout = seconds
+	}
+}
+
+// CertReloadInterval seconds between checks of certificate files for change
+func CertReloadInterval(seconds int) Option {
+	return func(o *Options) {
+		o.CertReloadInterval = seconds
+	}
+}
+
+// ReloadOnSIGHUP reload certificates of tls listeners on SIGHUP while running
+func ReloadOnSIGHUP() Option {
+	return func(o *Options) {
+		o.ReloadOnSIGHUP = true
+	}
+}
+
+// RequestTimeout seconds to serve a route before responding 503, see Timeout
+func RequestTimeout(seconds int) Option {
+	return func(o *Options) {
+		o.RequestTimeout = seconds
+	}
+}
+
+// DisableAccessLog turn off the built-in access log
+func DisableAccessLog() Option {
+	return func(o *Options) {
+		o.DisableAccessLog = true
+	}
+}
+
+// TreeRouting match plain paths like /users exactly by the radix tree. Without it they are unanchored
+// regexes matched by Mux as before, so "/api/" serves "/api/users" and "/v1/api/", only patterns with
+// `:param` or `*wildcard` segments are radix tree patterns.
+func TreeRouting() Option {
+	return func(o *Options) {
+		o.TreeRouting = true
+	}
+}
+
 // StaticPath StaticPath
 func StaticPath(urlpath string, webpath ...string) Option {
 	return func(o *Options) {
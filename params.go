@@ -0,0 +1,102 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Params return the named path parameters captured by the route, valid until the request finishes
+// as the map is reused by the next request, see Copy
+func (ctx *Context) Params() map[string]string {
+	return ctx.params
+}
+
+func (ctx *Context) setParam(name, value string) {
+	if ctx.params == nil {
+		ctx.params = make(map[string]string)
+	}
+	ctx.params[name] = value
+}
+
+// Param return path parameter by name, empty if not exist
+func (ctx *Context) Param(name string, v ...string) string {
+	if value, ok := ctx.params[name]; ok && value != "" {
+		return value
+	}
+	if len(v) != 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// ParamInt return path parameter as int, response 400 if parse fail
+func (ctx *Context) ParamInt(name string) (int, error) {
+	value, err := strconv.Atoi(ctx.Param(name))
+	if err != nil {
+		return 0, ctx.badParam(name, err)
+	}
+	return value, nil
+}
+
+// ParamInt64 return path parameter as int64, response 400 if parse fail
+func (ctx *Context) ParamInt64(name string) (int64, error) {
+	value, err := strconv.ParseInt(ctx.Param(name), 10, 64)
+	if err != nil {
+		return 0, ctx.badParam(name, err)
+	}
+	return value, nil
+}
+
+// ParamUint return path parameter as uint, response 400 if parse fail
+func (ctx *Context) ParamUint(name string) (uint, error) {
+	value, err := strconv.ParseUint(ctx.Param(name), 10, 0)
+	if err != nil {
+		return 0, ctx.badParam(name, err)
+	}
+	return uint(value), nil
+}
+
+// ParamUint64 return path parameter as uint64, response 400 if parse fail
+func (ctx *Context) ParamUint64(name string) (uint64, error) {
+	value, err := strconv.ParseUint(ctx.Param(name), 10, 64)
+	if err != nil {
+		return 0, ctx.badParam(name, err)
+	}
+	return value, nil
+}
+
+// ParamUUID return path parameter as lower-case canonical uuid, response 400 if parse fail
+func (ctx *Context) ParamUUID(name string) (string, error) {
+	value := strings.ToLower(ctx.Param(name))
+	if !isUUID(value) {
+		return "", ctx.badParam(name, fmt.Errorf("invalid uuid %q", value))
+	}
+	return value, nil
+}
+
+func (ctx *Context) badParam(name string, err error) error {
+	ctx.Error(http.StatusBadRequest)
+	return fmt.Errorf("param %s: %w", name, err)
+}
+
+// isUUID check s is xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, hex in lower case
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
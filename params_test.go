@@ -0,0 +1,61 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/corex-io/web"
+)
+
+func TestParams(t *testing.T) {
+	app := web.New()
+	app.RouteFunc(`^/users/(?P<id>[^/]+)/files/(?P<uuid>[^/]+)$`, func(ctx *web.Context) {
+		id, err := ctx.ParamInt("id")
+		if err != nil {
+			return
+		}
+		uuid, err := ctx.ParamUUID("uuid")
+		if err != nil {
+			return
+		}
+		if ctx.Param("missing", "default") != "default" {
+			t.Errorf("param default not used")
+		}
+		if len(ctx.Params()) != 2 {
+			t.Errorf("params: %v", ctx.Params())
+		}
+		ctx.JSON(map[string]interface{}{"id": id, "uuid": uuid}, 0, nil)
+	})
+
+	cases := []struct {
+		path string
+		code int
+	}{
+		{"/users/42/files/0F8FAD5B-D9CB-469F-A165-70867728950E", http.StatusOK},
+		{"/users/abc/files/0f8fad5b-d9cb-469f-a165-70867728950e", http.StatusBadRequest},
+		{"/users/42/files/not-a-uuid", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.code {
+			t.Errorf("%s: expect %d, got %d", c.path, c.code, w.Code)
+		}
+	}
+}
+
+func TestMultiplexerFindRouteParams(t *testing.T) {
+	mux := web.NewMultiplexer()
+	mux.RouteFunc(`^/users/(?P<id>\d+)$`, func(ctx *web.Context) {})
+	entry := mux.FindRoute("/users/42")
+	if entry == nil || entry.Pattern() != `^/users/(?P<id>\d+)$` {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+	if found, params := mux.FindRouteParams("/users/42"); found != entry || params["id"] != "42" || len(params) != 1 {
+		t.Errorf("unexpected params %v", params)
+	}
+	if mux.FindRoute("/users/bob") != nil {
+		t.Errorf("expect no route")
+	}
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// errContextReleased panic message of using a Context after its request finished
+const errContextReleased = "web: Context used after the request finished, pass ctx.Copy() to goroutines outliving the handler"
+
+// newContext new an idle Context for the pool, its generation is odd until acquired
+func newContext() interface{} {
+	return &Context{gen: 1}
+}
+
+// acquire get a Context from the pool for req, bumping its generation to even
+func (s *Web) acquire(resp http.ResponseWriter, req *http.Request, now time.Time) *Context {
+	ctx := s.Get().(*Context)
+	atomic.AddUint32(&ctx.gen, 1)
+	ctx.rw = responseWriter{ResponseWriter: resp, startedAt: now}
+	ctx.writer = &ctx.rw
+	ctx.ResponseWriter = ctx.writer
+	ctx.Request = req
+	ctx.web = s
+	ctx.Timestamp = now
+	ctx.Logger = s.Log
+	return ctx
+}
+
+// release bump the generation of ctx to odd, reset it keeping the storage of handlers, params and
+// values, and put it back to the pool. A goroutine still holding ctx fails checkReleased until the
+// Context serves another request, use ctx.Copy() for goroutines outliving the handler.
+func (s *Web) release(ctx *Context) {
+	atomic.AddUint32(&ctx.gen, 1)
+	ctx.reset()
+	s.Put(ctx)
+}
+
+// Generation return the generation of ctx, bumped when a request starts and when it finishes. A
+// goroutine launched by the handler may compare it with the value taken in the handler to detect ctx
+// was released or serves another request since.
+func (ctx *Context) Generation() uint32 {
+	return atomic.LoadUint32(&ctx.gen)
+}
+
+// checkReleased panic if ctx is idle in the pool, as a goroutine launched by the handler is still using it
+func (ctx *Context) checkReleased() {
+	if atomic.LoadUint32(&ctx.gen)&1 != 0 {
+		panic(errContextReleased)
+	}
+}
+
+// Copy return a copy of ctx for goroutines outliving the handler, it reads the request, params,
+// values and trace of ctx, but can't write the response
+func (ctx *Context) Copy() *Context {
+	ctx.checkReleased()
+	c := &Context{
+		ResponseWriter: releasedWriter{},
+		Request:        ctx.Request,
+		web:            ctx.web,
+		statusCode:     ctx.Status(),
+		entry:          ctx.entry,
+		Timestamp:      ctx.Timestamp,
+		Logger:         ctx.Logger,
+	}
+	if len(ctx.params) != 0 {
+		c.params = make(map[string]string, len(ctx.params))
+		for k, v := range ctx.params {
+			c.params[k] = v
+		}
+	}
+	if len(ctx.values) != 0 {
+		c.values = make(map[string]interface{}, len(ctx.values))
+		for k, v := range ctx.values {
+			c.values[k] = v
+		}
+	}
+	return c
+}
+
+// releasedWriter ResponseWriter of released and copied Contexts, panic on use
+type releasedWriter struct{}
+
+func (releasedWriter) Header() http.Header {
+	panic(errContextReleased)
+}
+
+func (releasedWriter) Write([]byte) (int, error) {
+	panic(errContextReleased)
+}
+
+func (releasedWriter) WriteHeader(int) {
+	panic(errContextReleased)
+}
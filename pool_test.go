@@ -0,0 +1,100 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/corex-io/web"
+)
+
+func TestContextPool(t *testing.T) {
+	app := web.New(web.DisableAccessLog())
+	var leaked, copied *web.Context
+	var gen uint32
+	app.RouteFunc("/users/:id", func(ctx *web.Context) {
+		if _, ok := ctx.Get("user"); ok {
+			t.Errorf("value leaked from previous request")
+		}
+		if ctx.Params()["name"] != "" {
+			t.Errorf("param leaked from previous request")
+		}
+		ctx.Set("user", ctx.Param("id"))
+		leaked, copied, gen = ctx, ctx.Copy(), ctx.Generation()
+		ctx.Text([]byte(ctx.GetString("user")))
+	})
+	app.RouteFunc("/names/:name", func(ctx *web.Context) {
+		ctx.Text([]byte(ctx.Param("name")))
+	})
+
+	for _, path := range []string{"/users/1", "/names/bob", "/users/2"} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if expect := path[strings.LastIndex(path, "/")+1:]; w.Body.String() != expect {
+			t.Errorf("expect %s, got %s", expect, w.Body)
+		}
+	}
+	if leaked.Generation() == gen {
+		t.Errorf("generation %d not bumped on release", gen)
+	}
+
+	if copied.Param("id") != "2" || copied.GetString("user") != "2" {
+		t.Errorf("copy lost request data: %q %q", copied.Param("id"), copied.GetString("user"))
+	}
+	for name, use := range map[string]func(){
+		"Set":   func() { leaked.Set("k", 1) },
+		"Next":  func() { leaked.Next() },
+		"Write": func() { _, _ = leaked.ResponseWriter.Write(nil) },
+		"Copy":  func() { _, _ = copied.ResponseWriter.Write(nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s after release didn't panic", name)
+				}
+			}()
+			use()
+		}()
+	}
+}
+
+func benchmarkServeHTTP(b *testing.B, app *web.Web, path string) {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Body.Reset()
+		app.ServeHTTP(w, req)
+	}
+}
+
+func BenchmarkServeHTTPStatic(b *testing.B) {
+	app := web.New(web.DisableAccessLog())
+	app.RouteFunc("/ping", func(ctx *web.Context) {
+		ctx.Text([]byte("pong"))
+	})
+	benchmarkServeHTTP(b, app, "/ping")
+}
+
+func BenchmarkServeHTTPParam(b *testing.B) {
+	app := web.New(web.DisableAccessLog())
+	app.RouteFunc("/users/:id", func(ctx *web.Context) {
+		ctx.Text([]byte(ctx.Param("id")))
+	})
+	benchmarkServeHTTP(b, app, "/users/42")
+}
+
+func BenchmarkServeHTTPMiddleware(b *testing.B) {
+	app := web.New(web.DisableAccessLog())
+	app.Use(func(ctx *web.Context) {
+		ctx.Set("user", "bob")
+	}, func(ctx *web.Context) {
+		ctx.Next()
+	})
+	app.RouteFunc("/ping", func(ctx *web.Context) {
+		ctx.Text([]byte(ctx.GetString("user")))
+	})
+	benchmarkServeHTTP(b, app, "/ping")
+}
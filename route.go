@@ -9,10 +9,29 @@ import (
 
 // Entry route entry point
 type Entry struct {
+	pattern     string
 	regex       *regexp.Regexp
+	implements  map[string]bool
+	allow       string
+	group       *Group
 	MyInterface Handler
 }
 
+func newEntry(pattern string, handler Handler) *Entry {
+	implements := implementedMethods(handler)
+	return &Entry{
+		pattern:     pattern,
+		implements:  implements,
+		allow:       allowHeader(implements),
+		MyInterface: handler,
+	}
+}
+
+// Pattern return the pattern the entry was registered with
+func (e *Entry) Pattern() string {
+	return e.pattern
+}
+
 // Multiplexer mux
 type Multiplexer []*Entry
 
@@ -36,11 +55,12 @@ func (mux *Multiplexer) HandleFunc(path string, f http.HandlerFunc) {
 
 // Route handle
 func (mux *Multiplexer) Route(path string, handler Handler) {
-	entry := Entry{
-		regex:       regexp.MustCompile(path),
-		MyInterface: handler,
-	}
-	*mux = append(*mux, &entry)
+	mux.add(newEntry(path, handler))
+}
+
+func (mux *Multiplexer) add(entry *Entry) {
+	entry.regex = regexp.MustCompile(entry.pattern)
+	*mux = append(*mux, entry)
 }
 
 // RouteFunc route handlerFunc
@@ -50,6 +70,16 @@ func (mux *Multiplexer) RouteFunc(path string, f HandlerFunc) {
 
 // FindRoute find router
 func (mux Multiplexer) FindRoute(path string) *Entry {
+	for _, m := range mux {
+		if m.regex.MatchString(path) {
+			return m
+		}
+	}
+	return nil
+}
+
+// FindRouteParams find router, return the entry and the named subexpressions of its regex
+func (mux Multiplexer) FindRouteParams(path string) (*Entry, map[string]string) {
 	for _, m := range mux {
 		if matchs := m.regex.FindStringSubmatch(path); matchs != nil {
 			match := make(map[string]string, len(matchs))
@@ -57,11 +87,12 @@ func (mux Multiplexer) FindRoute(path string) *Entry {
 				match[value] = matchs[idx]
 			}
 			delete(match, "")
-			return m
+			return m, match
 		}
 	}
-	return nil
+	return nil, nil
 }
+
 func (mux Multiplexer) String() string {
 	var res []string
 	for _, m := range mux {
@@ -0,0 +1,342 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFingerprint match fingerprinted asset names like app.3f2a9c1b.js or logo-3f2a9c1b.png
+var DefaultFingerprint = regexp.MustCompile(`[.-][0-9a-fA-F]{8,}\.[^/]+$`)
+
+// precompressed content codings served from sibling files, in server preference order
+var precompressed = []struct{ coding, ext string }{{"br", ".br"}, {"gzip", ".gz"}}
+
+// StaticOptions options of FileServer
+type StaticOptions struct {
+	Index         string         // file served for directories, index.html by default
+	Browse        bool           // list directories without index
+	SPA           bool           // serve the root index for pages not found, for single page apps
+	Precompressed bool           // serve .br and .gz siblings to clients accepting them, on by default
+	MaxAge        int            // seconds cached of files not fingerprinted, revalidated every time if 0
+	Fingerprint   *regexp.Regexp // names of assets never changing, cached as immutable for a year
+}
+
+// StaticOption func
+type StaticOption func(*StaticOptions)
+
+// StaticIndex set the file served for directories
+func StaticIndex(name string) StaticOption {
+	return func(o *StaticOptions) {
+		o.Index = name
+	}
+}
+
+// StaticBrowse list directories without index
+func StaticBrowse() StaticOption {
+	return func(o *StaticOptions) {
+		o.Browse = true
+	}
+}
+
+// StaticSPA serve the root index for paths not found without a file extension, or requested by
+// clients accepting text/html, so missing assets are still 404
+func StaticSPA() StaticOption {
+	return func(o *StaticOptions) {
+		o.SPA = true
+	}
+}
+
+// StaticPrecompressed turn serving .br and .gz siblings on or off
+func StaticPrecompressed(on bool) StaticOption {
+	return func(o *StaticOptions) {
+		o.Precompressed = on
+	}
+}
+
+// StaticMaxAge set seconds cached of files not fingerprinted
+func StaticMaxAge(seconds int) StaticOption {
+	return func(o *StaticOptions) {
+		o.MaxAge = seconds
+	}
+}
+
+// StaticFingerprint set the pattern of fingerprinted asset names, nil to cache none as immutable
+func StaticFingerprint(re *regexp.Regexp) StaticOption {
+	return func(o *StaticOptions) {
+		o.Fingerprint = re
+	}
+}
+
+// FileServer serve files of a fs.FS, like os.DirFS or embed.FS, by the request path, answering
+// Range and conditional requests with strong ETags from the content
+type FileServer struct {
+	fsys  fs.FS
+	opts  StaticOptions
+	etags sync.Map // name -> staticETag
+}
+
+type staticETag struct {
+	modtime time.Time
+	size    int64
+	etag    string
+}
+
+// NewFileServer new file server of fsys
+func NewFileServer(fsys fs.FS, opts ...StaticOption) *FileServer {
+	options := StaticOptions{Index: "index.html", Precompressed: true, Fingerprint: DefaultFingerprint}
+	for _, o := range opts {
+		o(&options)
+	}
+	return &FileServer{fsys: fsys, opts: options}
+}
+
+// Static serve fsys under the path prefix
+func (s *Web) Static(prefix string, fsys fs.FS, opts ...StaticOption) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	s.Handle(prefix+"/*filepath", http.StripPrefix(prefix, NewFileServer(fsys, opts...)))
+}
+
+// staticPath a prefix of Options.StaticPaths
+type staticPath struct {
+	prefix string
+	server *FileServer
+}
+
+// staticPaths return servers of Options.StaticPaths, longest prefix first so overlapping prefixes
+// match deterministically
+func (s *Web) staticPaths() []staticPath {
+	if statics, _ := s.statics.Load().([]staticPath); statics != nil {
+		return statics
+	}
+	statics := make([]staticPath, 0, len(s.opts.StaticPaths))
+	for prefix, dir := range s.opts.StaticPaths {
+		statics = append(statics, staticPath{prefix: prefix, server: NewFileServer(os.DirFS(dir))})
+	}
+	sort.Slice(statics, func(i, j int) bool {
+		if len(statics[i].prefix) != len(statics[j].prefix) {
+			return len(statics[i].prefix) > len(statics[j].prefix)
+		}
+		return statics[i].prefix < statics[j].prefix
+	})
+	s.statics.Store(statics)
+	return statics
+}
+
+// ServeHTTP serve the file of the request path
+func (fsrv *FileServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp.Header().Set("Allow", "GET, HEAD")
+		http.Error(resp, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	upath := req.URL.Path
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
+	}
+	name := strings.TrimPrefix(path.Clean(upath), "/")
+	if name == "" {
+		name = "."
+	}
+
+	info, err := fs.Stat(fsrv.fsys, name)
+	if err == nil && info.IsDir() {
+		if !strings.HasSuffix(upath, "/") {
+			redirectDir(resp, req)
+			return
+		}
+		index := path.Join(name, fsrv.opts.Index)
+		if indexInfo, err := fs.Stat(fsrv.fsys, index); err == nil && !indexInfo.IsDir() {
+			fsrv.serveFile(resp, req, index, indexInfo)
+			return
+		}
+		if fsrv.opts.Browse {
+			fsrv.listDir(resp, name)
+			return
+		}
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		page := path.Ext(name) == "" || acceptsHTML(req.Header.Values("Accept"))
+		if fsrv.opts.SPA && page && errors.Is(err, fs.ErrNotExist) {
+			if indexInfo, err := fs.Stat(fsrv.fsys, fsrv.opts.Index); err == nil && !indexInfo.IsDir() {
+				fsrv.serveFile(resp, req, fsrv.opts.Index, indexInfo)
+				return
+			}
+		}
+		code := toHTTPError(err)
+		if errors.Is(err, fs.ErrInvalid) {
+			code = http.StatusNotFound
+		}
+		http.Error(resp, http.StatusText(code), code)
+		return
+	}
+	fsrv.serveFile(resp, req, name, info)
+}
+
+// acceptsHTML return whether Accept lists text/html, as browsers navigating to a page do
+func acceptsHTML(accept []string) bool {
+	for _, ar := range parseAccept(accept) {
+		if ar.typ == "text" && ar.subtype == "html" && ar.q > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// serveFile serve name, or its precompressed sibling the client accepts
+func (fsrv *FileServer) serveFile(resp http.ResponseWriter, req *http.Request, name string, info fs.FileInfo) {
+	header := resp.Header()
+	contentType := mime.TypeByExtension(path.Ext(name))
+	served, servedInfo := name, info
+	if fsrv.opts.Precompressed {
+		AddVary(header, "Accept-Encoding")
+		if sibling, siblingInfo, coding := fsrv.precompressed(name, req.Header.Get("Accept-Encoding")); sibling != "" {
+			served, servedInfo = sibling, siblingInfo
+			header.Set("Content-Encoding", coding)
+		}
+	}
+
+	f, err := fsrv.fsys.Open(served)
+	if err != nil {
+		http.Error(resp, http.StatusText(toHTTPError(err)), toHTTPError(err))
+		return
+	}
+	defer f.Close()
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(resp, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(b)
+	}
+	if contentType == "" {
+		// sniff the uncompressed content
+		contentType = "application/octet-stream"
+		if served == name {
+			var buf [512]byte
+			n, _ := io.ReadFull(content, buf[:])
+			contentType = http.DetectContentType(buf[:n])
+			if _, err := content.Seek(0, io.SeekStart); err != nil {
+				http.Error(resp, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	header.Set("Content-Type", contentType)
+	etag, err := fsrv.etag(served, servedInfo, content)
+	if err != nil {
+		http.Error(resp, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", fsrv.cacheControl(name))
+	http.ServeContent(resp, req, name, info.ModTime(), content)
+}
+
+// precompressed return the sibling of name compressed in the coding the client prefers, empty if
+// the client prefers identity or no sibling exists
+func (fsrv *FileServer) precompressed(name, acceptEncoding string) (string, fs.FileInfo, string) {
+	if acceptEncoding == "" {
+		return "", nil, ""
+	}
+	offers := make([]string, 0, len(precompressed)+1)
+	infos := make(map[string]fs.FileInfo, len(precompressed))
+	for _, p := range precompressed {
+		if info, err := fs.Stat(fsrv.fsys, name+p.ext); err == nil && !info.IsDir() {
+			offers = append(offers, p.coding)
+			infos[p.coding] = info
+		}
+	}
+	if len(offers) == 0 {
+		return "", nil, ""
+	}
+	coding := NegotiateEncoding(acceptEncoding, append(offers, "identity")...)
+	for _, p := range precompressed {
+		if p.coding == coding {
+			return name + p.ext, infos[coding], coding
+		}
+	}
+	return "", nil, ""
+}
+
+// etag return the strong ETag of the content of name, cached until its modtime or size changes
+func (fsrv *FileServer) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if v, ok := fsrv.etags.Load(name); ok {
+		cached := v.(staticETag)
+		if cached.modtime.Equal(info.ModTime()) && cached.size == info.Size() {
+			return cached.etag, nil
+		}
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	fsrv.etags.Store(name, staticETag{modtime: info.ModTime(), size: info.Size(), etag: etag})
+	return etag, nil
+}
+
+func (fsrv *FileServer) cacheControl(name string) string {
+	switch {
+	case fsrv.opts.Fingerprint != nil && fsrv.opts.Fingerprint.MatchString(path.Base(name)):
+		return "public, max-age=31536000, immutable"
+	case fsrv.opts.MaxAge > 0 && path.Base(name) != fsrv.opts.Index:
+		return "public, max-age=" + strconv.Itoa(fsrv.opts.MaxAge)
+	}
+	return "no-cache"
+}
+
+func (fsrv *FileServer) listDir(resp http.ResponseWriter, name string) {
+	entries, err := fs.ReadDir(fsrv.fsys, name)
+	if err != nil {
+		http.Error(resp, http.StatusText(toHTTPError(err)), toHTTPError(err))
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	var buf bytes.Buffer
+	buf.WriteString("<!DOCTYPE html>\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		u := url.URL{Path: entryName}
+		fmt.Fprintf(&buf, "<a href=\"%s\">%s</a>\n", html.EscapeString(u.String()), html.EscapeString(entryName))
+	}
+	buf.WriteString("</pre>\n")
+	resp.Header().Set("Content-Type", "text/html; charset=utf-8")
+	resp.Header().Set("Cache-Control", "no-cache")
+	_, _ = buf.WriteTo(resp)
+}
+
+func redirectDir(resp http.ResponseWriter, req *http.Request) {
+	target := path.Base(req.URL.Path) + "/"
+	if req.URL.RawQuery != "" {
+		target += "?" + req.URL.RawQuery
+	}
+	// relative, since the path may be stripped of a prefix, http.Redirect would make it absolute
+	resp.Header().Set("Location", target)
+	resp.WriteHeader(http.StatusMovedPermanently)
+}
@@ -0,0 +1,162 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/corex-io/web"
+)
+
+func TestStatic(t *testing.T) {
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":        {Data: []byte("<h1>app</h1>"), ModTime: modtime},
+		"app.js":            {Data: []byte("console.log(1)"), ModTime: modtime},
+		"app.js.br":         {Data: []byte("br-data"), ModTime: modtime},
+		"app.js.gz":         {Data: []byte("gz-data"), ModTime: modtime},
+		"app.3f2a9c1b.js":   {Data: []byte("hashed"), ModTime: modtime},
+		"docs/readme.txt":   {Data: []byte("readme"), ModTime: modtime},
+		"docs/<script>.txt": {Data: []byte("x"), ModTime: modtime},
+	}
+
+	app := web.New(web.DisableAccessLog())
+	app.Static("/assets", fsys)
+	app.Static("/browse", fsys, web.StaticBrowse(), web.StaticMaxAge(60))
+	app.Static("/spa", fsys, web.StaticSPA())
+
+	serve := func(method, path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodGet, "/assets/app.js", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != "console.log(1)" || !strings.HasPrefix(etag, `"`) ||
+		strings.HasPrefix(etag, "W/") || w.Header().Get("Cache-Control") != "no-cache" ||
+		w.Header().Get("Vary") != "Accept-Encoding" || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("unexpected response %d %v %q", w.Code, w.Header(), w.Body)
+	}
+	if w := serve(http.MethodGet, "/assets/app.js", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Errorf("expect 304, got %d", w.Code)
+	}
+
+	for accept, expect := range map[string]string{
+		"gzip, br":             "br-data",
+		"gzip":                 "gz-data",
+		"br;q=0.5, gzip":       "gz-data",
+		"br;q=0, gzip;q=0":     "console.log(1)",
+		"identity, gzip;q=0.1": "console.log(1)",
+		// identity not listed is preferred least
+		"gzip;q=0.5":           "gz-data",
+		"br;q=0.1, gzip;q=0.2": "gz-data",
+	} {
+		w := serve(http.MethodGet, "/assets/app.js", map[string]string{"Accept-Encoding": accept})
+		if w.Body.String() != expect || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript") {
+			t.Errorf("Accept-Encoding %q: unexpected response %v %q", accept, w.Header(), w.Body)
+		}
+		if coding := w.Header().Get("Content-Encoding"); (coding == "") != (expect == "console.log(1)") {
+			t.Errorf("Accept-Encoding %q: unexpected Content-Encoding %q", accept, coding)
+		}
+	}
+
+	if w := serve(http.MethodGet, "/assets/app.3f2a9c1b.js", nil); w.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Errorf("unexpected Cache-Control %q", w.Header().Get("Cache-Control"))
+	}
+	if w := serve(http.MethodGet, "/assets/", nil); w.Code != http.StatusOK || w.Body.String() != "<h1>app</h1>" {
+		t.Errorf("unexpected index %d %q", w.Code, w.Body)
+	}
+	if w := serve(http.MethodGet, "/assets/docs", nil); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "docs/" {
+		t.Errorf("unexpected redirect %d %v", w.Code, w.Header())
+	}
+	if w := serve(http.MethodGet, "/assets/docs/", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expect 404 for directory without index, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/assets/missing.js", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expect 404, got %d", w.Code)
+	}
+	if w := serve(http.MethodPost, "/assets/app.js", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expect 405, got %d", w.Code)
+	}
+
+	w = serve(http.MethodGet, "/browse/docs/", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<a href="readme.txt">readme.txt</a>`) ||
+		strings.Contains(w.Body.String(), "<script>") {
+		t.Errorf("unexpected listing %d %q", w.Code, w.Body)
+	}
+	if w := serve(http.MethodGet, "/browse/app.js", nil); w.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Errorf("unexpected Cache-Control %q", w.Header().Get("Cache-Control"))
+	}
+
+	if w := serve(http.MethodGet, "/spa/users/42", nil); w.Code != http.StatusOK || w.Body.String() != "<h1>app</h1>" {
+		t.Errorf("unexpected spa fallback %d %q", w.Code, w.Body)
+	}
+	if w := serve(http.MethodGet, "/spa/users/john.doe", map[string]string{"Accept": "text/html,*/*;q=0.8"}); w.Code != http.StatusOK || w.Body.String() != "<h1>app</h1>" {
+		t.Errorf("unexpected spa fallback for html %d %q", w.Code, w.Body)
+	}
+	for _, accept := range []string{"", "*/*", "text/html;q=0, */*"} {
+		if w := serve(http.MethodGet, "/spa/app.missing.js", map[string]string{"Accept": accept}); w.Code != http.StatusNotFound {
+			t.Errorf("Accept %q: expect 404 for missing asset, got %d", accept, w.Code)
+		}
+	}
+}
+
+func TestStaticPath(t *testing.T) {
+	root, img := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("root"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(img, "a.txt"), []byte("img"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	app := web.New(web.DisableAccessLog(), web.StaticPath("/static", root), web.StaticPath("/static/img", img))
+	app.HandleFs("/files", root)
+
+	for path, expect := range map[string]string{"/static/a.txt": "root", "/static/img/a.txt": "img", "/files/a.txt": "root"} {
+		for i := 0; i < 10; i++ {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusOK || w.Body.String() != expect {
+				t.Fatalf("%s: unexpected response %d %q", path, w.Code, w.Body)
+			}
+		}
+	}
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/files/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expect 404 for directory listing, got %d", w.Code)
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	for _, c := range []struct {
+		accept string
+		offers []string
+		expect string
+	}{
+		{"", []string{"gzip", "identity"}, "identity"},
+		{"gzip, deflate", []string{"br", "gzip", "deflate"}, "gzip"},
+		{"gzip;q=0.5, deflate", []string{"gzip", "deflate"}, "deflate"},
+		{"*", []string{"br", "gzip"}, "br"},
+		{"*;q=0, gzip", []string{"br", "gzip"}, "gzip"},
+		{"identity;q=0", []string{"identity"}, ""},
+		{"br", []string{"gzip", "identity"}, "identity"},
+		{"gzip;q=0.5", []string{"identity", "gzip"}, "gzip"},
+		{"gzip;q=0.5, identity;q=0.8", []string{"gzip", "identity"}, "identity"},
+		{"*;q=0.3", []string{"identity", "gzip"}, "identity"},
+	} {
+		if got := web.NegotiateEncoding(c.accept, c.offers...); got != c.expect {
+			t.Errorf("NegotiateEncoding(%q, %v) = %q, expect %q", c.accept, c.offers, got, c.expect)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TemplateOptions options of Templates
+type TemplateOptions struct {
+	Ext        []string         // file extensions of templates, .html by default
+	Funcs      template.FuncMap // functions available to all templates
+	SharedDirs []string         // dirs of layouts and partials parsed into every page, "layouts" and "partials" by default
+	Layout     string           // shared template executed for every page, which fills its blocks; pages are executed directly if empty
+	Dev        bool             // reparse templates when files change, for development
+}
+
+// TemplateOption func
+type TemplateOption func(*TemplateOptions)
+
+// TemplateExt set the file extensions of templates
+func TemplateExt(ext ...string) TemplateOption {
+	return func(o *TemplateOptions) {
+		o.Ext = ext
+	}
+}
+
+// TemplateFuncs add functions available to all templates
+func TemplateFuncs(funcs template.FuncMap) TemplateOption {
+	return func(o *TemplateOptions) {
+		for name, f := range funcs {
+			o.Funcs[name] = f
+		}
+	}
+}
+
+// TemplateSharedDirs set the dirs of layouts and partials parsed into every page
+func TemplateSharedDirs(dirs ...string) TemplateOption {
+	return func(o *TemplateOptions) {
+		o.SharedDirs = dirs
+	}
+}
+
+// TemplateLayout execute the shared template name for every page, like "layouts/base.html"
+// with {{block "content" .}}{{end}} defined by pages
+func TemplateLayout(name string) TemplateOption {
+	return func(o *TemplateOptions) {
+		o.Layout = name
+	}
+}
+
+// TemplateDev reparse templates when files change
+func TemplateDev() TemplateOption {
+	return func(o *TemplateOptions) {
+		o.Dev = true
+	}
+}
+
+// Templates html templates parsed once from a fs.FS, like os.DirFS or embed.FS. Templates are named
+// by their slash separated path in the fs, every page is parsed with the shared layouts and partials,
+// so pages can define the same blocks and include partials by {{template "partials/nav.html" .}}.
+type Templates struct {
+	fsys  fs.FS
+	opts  TemplateOptions
+	mu    sync.RWMutex
+	pages map[string]*template.Template
+	stamp string // names, sizes and mod times of the files parsed
+}
+
+// NewTemplates parse the templates in fsys
+func NewTemplates(fsys fs.FS, opts ...TemplateOption) (*Templates, error) {
+	options := TemplateOptions{
+		Ext:        []string{".html"},
+		Funcs:      template.FuncMap{},
+		SharedDirs: []string{"layouts", "partials"},
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	t := &Templates{fsys: fsys, opts: options}
+	files, stamp, err := t.files()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.parse(files, stamp); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// files return template files sorted by name, and the stamp of them
+func (t *Templates) files() ([]string, string, error) {
+	var files []string
+	var stamp strings.Builder
+	err := fs.WalkDir(t.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !t.isTemplate(name) {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, name)
+		fmt.Fprintf(&stamp, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	sort.Strings(files)
+	return files, stamp.String(), err
+}
+
+func (t *Templates) isTemplate(name string) bool {
+	for _, ext := range t.opts.Ext {
+		if path.Ext(name) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Templates) isShared(name string) bool {
+	for _, dir := range t.opts.SharedDirs {
+		if strings.HasPrefix(name, strings.TrimSuffix(dir, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Templates) parse(files []string, stamp string) error {
+	base := template.New("").Funcs(t.opts.Funcs)
+	var pages []string
+	for _, name := range files {
+		if !t.isShared(name) {
+			pages = append(pages, name)
+			continue
+		}
+		if err := parseTemplate(t.fsys, base.New(name), name); err != nil {
+			return err
+		}
+	}
+	if t.opts.Layout != "" && base.Lookup(t.opts.Layout) == nil {
+		return fmt.Errorf("template: layout %q not found", t.opts.Layout)
+	}
+
+	set := make(map[string]*template.Template, len(pages))
+	for _, name := range pages {
+		page, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if err := parseTemplate(t.fsys, page.New(name), name); err != nil {
+			return err
+		}
+		set[name] = page
+	}
+
+	t.mu.Lock()
+	t.pages, t.stamp = set, stamp
+	t.mu.Unlock()
+	return nil
+}
+
+func parseTemplate(fsys fs.FS, tpl *template.Template, name string) error {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	_, err = tpl.Parse(string(b))
+	return err
+}
+
+// reload reparse the templates if the files changed since parsed
+func (t *Templates) reload() error {
+	files, stamp, err := t.files()
+	if err != nil {
+		return err
+	}
+	t.mu.RLock()
+	changed := stamp != t.stamp
+	t.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	return t.parse(files, stamp)
+}
+
+// Execute write page name executed with data to w, through the layout if set
+func (t *Templates) Execute(w io.Writer, name string, data interface{}) error {
+	if t.opts.Dev {
+		if err := t.reload(); err != nil {
+			return err
+		}
+	}
+	t.mu.RLock()
+	page, ok := t.pages[name]
+	t.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("template: page %q not found", name)
+	}
+	if t.opts.Layout != "" {
+		return page.ExecuteTemplate(w, t.opts.Layout, data)
+	}
+	return page.ExecuteTemplate(w, name, data)
+}
+
+// LoadTemplates parse the templates in fsys for Context.HTML and Context.Render
+func (s *Web) LoadTemplates(fsys fs.FS, opts ...TemplateOption) error {
+	t, err := NewTemplates(fsys, opts...)
+	if err != nil {
+		return err
+	}
+	s.templates = t
+	return nil
+}
+
+// HTML respond page name of the templates loaded by Web.LoadTemplates with status. The page is
+// executed into a buffer, so errors respond 500 instead of a partial page.
+func (ctx *Context) HTML(status int, name string, data interface{}) {
+	if ctx.web == nil || ctx.web.templates == nil {
+		ctx.HandleError(fmt.Errorf("template: no templates loaded for %q", name))
+		return
+	}
+	var buf bytes.Buffer
+	if err := ctx.web.templates.Execute(&buf, name, data); err != nil {
+		ctx.HandleError(err)
+		return
+	}
+	ctx.writeHTML(status, &buf)
+}
+
+func (ctx *Context) writeHTML(status int, buf *bytes.Buffer) {
+	ctx.SetStatusCode(status)
+	ctx.ResponseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	ctx.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	ctx.ResponseWriter.WriteHeader(status)
+	_, _ = buf.WriteTo(ctx.ResponseWriter)
+}
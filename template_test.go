@@ -0,0 +1,80 @@
+package web_test
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/corex-io/web"
+)
+
+func TestTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.html":  {Data: []byte(`<title>{{block "title" .}}site{{end}}</title>{{template "partials/nav.html" .}}{{block "content" .}}{{end}}`)},
+		"partials/nav.html":  {Data: []byte(`<nav>{{upper .User}}</nav>`)},
+		"users/show.html":    {Data: []byte(`{{define "title"}}user{{end}}{{define "content"}}<p>{{.User}}</p>{{end}}`)},
+		"home.html":          {Data: []byte(`{{define "content"}}home{{end}}`)},
+		"broken.html":        {Data: []byte(`{{define "content"}}{{.User.Missing}}{{end}}`)},
+		"assets/ignored.css": {Data: []byte(`{{`)},
+	}
+	app := web.New(web.DisableAccessLog())
+	err := app.LoadTemplates(fsys, web.TemplateLayout("layouts/base.html"),
+		web.TemplateFuncs(template.FuncMap{"upper": strings.ToUpper}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.RouteFunc("/:page", func(ctx *web.Context) {
+		ctx.HTML(http.StatusCreated, ctx.Param("page")+".html", map[string]string{"User": "<bob>"})
+	})
+	app.RouteFunc("/users/show", func(ctx *web.Context) {
+		ctx.Render("users/show.html", map[string]string{"User": "amy"})
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := serve("/home")
+	if w.Code != http.StatusCreated || w.Body.String() != "<title>site</title><nav>&lt;BOB&gt;</nav>home" ||
+		w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Body)
+	}
+	if w := serve("/users/show"); w.Code != http.StatusOK || w.Body.String() != "<title>user</title><nav>AMY</nav><p>amy</p>" {
+		t.Errorf("unexpected response %d %q", w.Code, w.Body)
+	}
+	for _, path := range []string{"/broken", "/missing"} {
+		if w := serve(path); w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "<title>") {
+			t.Errorf("%s: expect 500 without partial page, got %d %q", path, w.Code, w.Body)
+		}
+	}
+}
+
+func TestTemplatesDev(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "index.html")
+	if err := os.WriteFile(page, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tpls, err := web.NewTemplates(os.DirFS(dir), web.TemplateDev())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := tpls.Execute(&b, "index.html", nil); err != nil || b.String() != "v1" {
+		t.Fatalf("unexpected %q %v", b.String(), err)
+	}
+	if err := os.WriteFile(page, []byte("version 2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	if err := tpls.Execute(&b, "index.html", nil); err != nil || b.String() != "version 2" {
+		t.Errorf("template not reloaded: %q %v", b.String(), err)
+	}
+}
@@ -0,0 +1,157 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timeout run the rest of the chain with a deadline on the request context.Context, use it on a Group
+// for route-level timeouts, or RequestTimeout for all routes. The response of the chain is buffered and
+// sent once it returns; if it doesn't in d, a 503 is responded by Web.ErrorHandler and later writes of
+// the chain fail with http.ErrHandlerTimeout. If the client cancels first, nothing is responded and
+// StatusClientClosedRequest is recorded. Handlers should stop early on ctx.Done(). Flush and Hijack
+// take no effect under a timeout.
+func Timeout(d time.Duration) func(*Context) {
+	return func(ctx *Context) {
+		if d <= 0 {
+			return
+		}
+		c, cancel := context.WithTimeout(ctx.Request.Context(), d)
+		defer cancel()
+
+		tw := &timeoutWriter{header: ctx.ResponseWriter.Header().Clone()}
+		// the chain runs on a copy of ctx, so the copy can be left behind on timeout without racing
+		inner := *ctx
+		req := ctx.Request.WithContext(c)
+		inner.Request = req
+		inner.handlers = append(make([]func(*Context), 0, len(ctx.handlers)), ctx.handlers...)
+		inner.writer = newResponseWriter(tw, ctx.Timestamp)
+		inner.ResponseWriter = inner.writer
+		inner.params = make(map[string]string, len(ctx.params))
+		for k, v := range ctx.params {
+			inner.params[k] = v
+		}
+		inner.values = make(map[string]interface{}, len(ctx.values))
+		for k, v := range ctx.values {
+			inner.values[k] = v
+		}
+
+		done := make(chan struct{})
+		panicCh := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if v := recover(); v != nil {
+					panicCh <- v
+					return
+				}
+				close(done)
+			}()
+			inner.Next()
+		}()
+
+		select {
+		case v := <-panicCh:
+			ctx.merge(&inner, req)
+			panic(v)
+		case <-done:
+			ctx.merge(&inner, req)
+			tw.writeTo(ctx.ResponseWriter)
+		case <-c.Done():
+			tw.timeout()
+			ctx.Abort()
+			if errors.Is(c.Err(), context.Canceled) {
+				// the client is gone, nothing to respond
+				ctx.SetStatusCode(StatusClientClosedRequest)
+				return
+			}
+			ctx.HandleError(c.Err())
+		}
+	}
+}
+
+// merge take the state of the chain run on inner, including changes of its request like SetTrace,
+// keeping the deadline and cancellation of the request of ctx
+func (ctx *Context) merge(inner *Context, req *http.Request) {
+	if inner.Request != req {
+		ctx.Request = inner.Request.WithContext(valuesContext{Context: ctx.Request.Context(), values: inner.Request.Context()})
+	}
+	ctx.statusCode = inner.statusCode
+	ctx.params = inner.params
+	ctx.entry = inner.entry
+	ctx.panic = inner.panic
+	ctx.values = inner.values
+	ctx.Logger = inner.Logger
+	ctx.Abort()
+}
+
+// valuesContext context.Context of values, done with Context
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c valuesContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
+// timeoutWriter buffer the response until the chain returns, refuse writes after timeout
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.status != 0 || code < http.StatusOK {
+		return
+	}
+	w.status = code
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.buf.Write(b)
+}
+
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	w.timedOut = true
+	w.mu.Unlock()
+}
+
+// writeTo send the buffered response to dst
+func (w *timeoutWriter) writeTo(dst http.ResponseWriter) {
+	header := dst.Header()
+	for k := range header {
+		if _, ok := w.header[k]; !ok {
+			header.Del(k)
+		}
+	}
+	for k, v := range w.header {
+		header[k] = v
+	}
+	if w.status == 0 {
+		return
+	}
+	dst.WriteHeader(w.status)
+	_, _ = dst.Write(w.buf.Bytes())
+}
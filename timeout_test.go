@@ -0,0 +1,131 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/corex-io/web"
+)
+
+func TestTimeout(t *testing.T) {
+	app := web.New()
+	app.Use(func(ctx *web.Context) {
+		ctx.Set("user", "bob")
+	})
+	late := make(chan error, 1)
+	api := app.Group("/api", web.Timeout(50*time.Millisecond))
+	api.RouteFunc("/fast", func(ctx *web.Context) {
+		ctx.ResponseWriter.Header().Set("X-User", ctx.GetString("user"))
+		ctx.Set("handled", true)
+		ctx.Text([]byte("fast"))
+	})
+	api.RouteFunc("/slow", func(ctx *web.Context) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		_, err := ctx.ResponseWriter.Write([]byte("late"))
+		late <- err
+	})
+	api.RouteFunc("/panic", func(ctx *web.Context) {
+		panic("boom")
+	})
+	app.RouteFuncE("/deadline", func(ctx *web.Context) error {
+		return ctx.Err()
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := serve("/api/fast")
+	if w.Code != http.StatusOK || w.Body.String() != "fast" || w.Header().Get("X-User") != "bob" {
+		t.Errorf("unexpected response %d %q %v", w.Code, w.Body, w.Header())
+	}
+
+	w = serve("/api/slow")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect 503, got %d %s", w.Code, w.Body)
+	}
+	if err := <-late; err != http.ErrHandlerTimeout {
+		t.Errorf("expect ErrHandlerTimeout, got %v", err)
+	}
+
+	if w := serve("/api/panic"); w.Code != http.StatusInternalServerError {
+		t.Errorf("expect 500, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	c, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/deadline", nil).WithContext(c))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expect 503, got %d", w.Code)
+	}
+}
+
+func TestTimeoutRequestState(t *testing.T) {
+	app := web.New(web.DisableAccessLog())
+	type result struct {
+		status  int
+		trace   string
+		expired error
+	}
+	results := make(chan result, 1)
+	app.Use(func(ctx *web.Context) {
+		ctx.Next()
+		results <- result{ctx.Status(), ctx.Trace().RequestID, ctx.Err()}
+	})
+	started := make(chan struct{})
+	api := app.Group("/api", web.Timeout(time.Second))
+	api.RouteFunc("/traced", func(ctx *web.Context) {
+		ctx.SetTrace(web.Trace{RequestID: "req-1"})
+		ctx.Text([]byte("ok"))
+	})
+	api.RouteFunc("/wait", func(ctx *web.Context) {
+		close(started)
+		<-ctx.Done()
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/traced", nil))
+	if r := <-results; w.Body.String() != "ok" || r.trace != "req-1" || r.expired != nil {
+		t.Errorf("expect the trace kept after the chain, got %+v", r)
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/wait", nil).WithContext(c))
+	if r := <-results; r.status != web.StatusClientClosedRequest || w.Body.Len() != 0 {
+		t.Errorf("expect 499 recorded and nothing written, got %d %q", r.status, w.Body)
+	}
+}
+
+func TestContextValues(t *testing.T) {
+	app := web.New()
+	app.Use(func(ctx *web.Context) {
+		ctx.Set("tenant", "acme")
+		ctx.Set("id", 7)
+	})
+	app.RouteFunc("/", func(ctx *web.Context) {
+		if v, ok := ctx.Get("tenant"); !ok || v != "acme" {
+			t.Errorf("unexpected tenant %v", v)
+		}
+		if ctx.GetInt("id") != 7 || ctx.GetBool("missing") || ctx.Value("tenant") != "acme" {
+			t.Errorf("unexpected values")
+		}
+		ctx.Text([]byte("ok"))
+	})
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Body.String() != "ok" {
+		t.Errorf("unexpected response %s", w.Body)
+	}
+}
@@ -0,0 +1,249 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify-if-given":    tls.VerifyClientCertIfGiven,
+	"require-and-verify": tls.RequireAndVerifyClientCert,
+}
+
+// certReloader hold the certificate and client CA of a listener, reloaded when the files change
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTime   time.Time
+}
+
+func newCertReloader(l Listener) (*certReloader, error) {
+	r := &certReloader{certFile: l.CertFile, keyFile: l.KeyFile, caFile: l.ClientCAFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload load certificate and client CA from disk, keep the old ones if fail
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	var clientCAs *x509.CertPool
+	if r.caFile != "" {
+		b, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(b) {
+			return fmt.Errorf("no certificate found in %s", r.caFile)
+		}
+	}
+	r.mu.Lock()
+	r.cert, r.clientCAs, r.modTime = &cert, clientCAs, r.lastModified()
+	r.mu.Unlock()
+	return nil
+}
+
+// changed report whether any file was modified since last reload
+func (r *certReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastModified().After(r.modTime)
+}
+
+func (r *certReloader) lastModified() time.Time {
+	var last time.Time
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+	return last
+}
+
+// config return base with the current certificate and client CA
+func (r *certReloader) config(base *tls.Config) *tls.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cfg := base.Clone()
+	cfg.GetConfigForClient = nil
+	cfg.Certificates = []tls.Certificate{*r.cert}
+	cfg.ClientCAs = r.clientCAs
+	return cfg
+}
+
+// tlsConfig return the tls config of listener without certificates
+func (l Listener) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if l.MinVersion != "" {
+		version, ok := tlsVersions[l.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls version %q", l.MinVersion)
+		}
+		cfg.MinVersion = version
+	}
+	if len(l.CipherSuites) != 0 {
+		suites := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			suites[suite.Name] = suite.ID
+		}
+		for _, name := range l.CipherSuites {
+			id, ok := suites[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+			}
+			cfg.CipherSuites = append(cfg.CipherSuites, id)
+		}
+	}
+	if l.ClientCAFile != "" {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if l.ClientAuth != "" {
+		auth, ok := clientAuthTypes[strings.ToLower(l.ClientAuth)]
+		if !ok {
+			return nil, fmt.Errorf("unknown client auth %q", l.ClientAuth)
+		}
+		cfg.ClientAuth = auth
+	}
+	return cfg, nil
+}
+
+// useReloader take certificates of cfg from reloader on every handshake,
+// cfg must be complete (e.g. http2 configured) as it is the base of each clone.
+func useReloader(cfg *tls.Config, reloader *certReloader) {
+	base := cfg.Clone()
+	cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		return reloader.config(base), nil
+	}
+	cfg.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		reloader.mu.RLock()
+		defer reloader.mu.RUnlock()
+		return reloader.cert, nil
+	}
+}
+
+// ReloadCerts reload certificates and client CA of every tls listener from disk, a failing listener keeps
+// its old ones and its error is joined into the returned one
+func (s *Web) ReloadCerts() error {
+	s.mu.Lock()
+	reloaders := s.reloaders
+	s.mu.Unlock()
+	var errs []error
+	for _, r := range reloaders {
+		if err := r.reload(); err != nil {
+			errs = append(errs, fmt.Errorf("reload %s: %w", r.certFile, err))
+		}
+	}
+	s.Log.Infof("reload %d certificates", len(reloaders)-len(errs))
+	return joinErrors(errs...)
+}
+
+// watchCerts reload certificates on SIGHUP if ReloadOnSIGHUP, and every CertReloadInterval seconds if
+// files changed
+func (s *Web) watchCerts() (stop func()) {
+	done := make(chan struct{})
+	hup := make(chan os.Signal, 1)
+	if s.opts.ReloadOnSIGHUP {
+		signal.Notify(hup, syscall.SIGHUP)
+	}
+	var tick <-chan time.Time
+	var ticker *time.Ticker
+	if s.opts.CertReloadInterval > 0 {
+		ticker = time.NewTicker(time.Duration(s.opts.CertReloadInterval) * time.Second)
+		tick = ticker.C
+	}
+	go func() {
+		defer signal.Stop(hup)
+		if ticker != nil {
+			defer ticker.Stop()
+		}
+		for {
+			select {
+			case <-hup:
+				if err := s.ReloadCerts(); err != nil {
+					s.Log.Errorf("%v", err)
+				}
+			case <-tick:
+				s.reloadChangedCerts()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// reloadChangedCerts reload certificates whose files changed
+func (s *Web) reloadChangedCerts() {
+	s.mu.Lock()
+	reloaders := s.reloaders
+	s.mu.Unlock()
+	for _, r := range reloaders {
+		if !r.changed() {
+			continue
+		}
+		if err := r.reload(); err != nil {
+			s.Log.Errorf("reload %s: %v", r.certFile, err)
+			continue
+		}
+		s.Log.Infof("reload certificate %s", r.certFile)
+	}
+}
+
+// PeerCertificate return the verified client certificate of mutual tls, nil if none
+func (ctx *Context) PeerCertificate() *x509.Certificate {
+	if ctx.TLS == nil || len(ctx.TLS.VerifiedChains) == 0 || len(ctx.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return ctx.TLS.VerifiedChains[0][0]
+}
+
+// PeerNames return subject common name and SANs (dns, email, uri, ip) of the verified client certificate
+func (ctx *Context) PeerNames() []string {
+	cert := ctx.PeerCertificate()
+	if cert == nil {
+		return nil
+	}
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
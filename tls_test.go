@@ -0,0 +1,155 @@
+package web_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corex-io/web"
+	"github.com/corex-io/web/middleware"
+)
+
+func TestMutualTLSAndReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "localhost")
+	clientCert, clientKey := writeCert(t, dir, "client")
+	addr := freeAddress(t)
+
+	app := web.New(web.Listen(web.Listener{
+		Address:      addr,
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: clientCert,
+		MinVersion:   "1.2",
+	}))
+	app.Group("/admin", middleware.AccessCert("client")).RouteFunc("/whoami", func(ctx *web.Context) {
+		ctx.Text([]byte(ctx.PeerCertificate().Subject.CommonName))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run(ctx) }()
+
+	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			DisableKeepAlives: true,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, Certificates: certs},
+		}}
+	}
+	serverName := func(resp *http.Response) string {
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	var resp *http.Response
+	for i := 0; i < 100; i++ {
+		if resp, err = newClient(cert).Get("https://" + addr + "/admin/whoami"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "client" {
+		t.Errorf("expect 200 client, got %d %s", resp.StatusCode, body)
+	}
+	if name := serverName(resp); name != "localhost" {
+		t.Errorf("expect server cert localhost, got %s", name)
+	}
+
+	if _, err := newClient().Get("https://" + addr + "/admin/whoami"); err == nil {
+		t.Errorf("expect handshake fail without client certificate")
+	}
+
+	// replace server certificate on disk and reload
+	newCert, newKey := writeCert(t, dir, "reloaded")
+	for src, dst := range map[string]string{newCert: certFile, newKey: keyFile} {
+		if err := os.Rename(src, dst); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.ReloadCerts(); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = newClient(cert).Get("https://" + addr + "/admin/whoami")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if name := serverName(resp); name != "reloaded" {
+		t.Errorf("expect reloaded server cert, got %s", name)
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("expect nil on orderly stop, got %v", err)
+	}
+}
+
+func TestReloadCertsAll(t *testing.T) {
+	dir := t.TempDir()
+	brokenCert, brokenKey := writeCert(t, dir, "broken")
+	certFile, keyFile := writeCert(t, dir, "localhost")
+	addr := freeAddress(t)
+
+	app := web.New(web.Listen(
+		web.Listener{Address: freeAddress(t), CertFile: brokenCert, KeyFile: brokenKey},
+		web.Listener{Address: addr, CertFile: certFile, KeyFile: keyFile},
+	))
+	app.RouteFunc("/", func(ctx *web.Context) {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run(ctx) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}}
+	get := func() string {
+		resp, err := client.Get("https://" + addr + "/")
+		if err != nil {
+			return ""
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	for i := 0; i < 100 && get() == ""; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := ioutil.WriteFile(brokenCert, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	newCert, newKey := writeCert(t, dir, "reloaded")
+	for src, dst := range map[string]string{newCert: certFile, newKey: keyFile} {
+		if err := os.Rename(src, dst); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := app.ReloadCerts()
+	if err == nil || !strings.Contains(err.Error(), brokenCert) {
+		t.Errorf("expect error of %s, got %v", brokenCert, err)
+	}
+	if name := get(); name != "reloaded" {
+		t.Errorf("expect listener after the failing one reloaded, got %q", name)
+	}
+
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Errorf("expect nil on orderly stop, got %v", err)
+	}
+}
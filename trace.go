@@ -0,0 +1,176 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/corex-io/log"
+)
+
+// W3C trace context headers
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderTracestate  = "tracestate"
+)
+
+// Trace W3C trace context of a request
+type Trace struct {
+	TraceID   string // 32 lower hex
+	SpanID    string // 16 lower hex, span of this server
+	ParentID  string // 16 lower hex, span of the caller, empty if trace begins here
+	Flags     byte   // trace flags, 01 sampled
+	State     string // tracestate, passed through
+	RequestID string // legacy request id, TraceID if not given by the caller
+}
+
+type traceKey struct{}
+
+// ParseTraceparent parse traceparent header `00-<trace-id>-<parent-id>-<flags>`, the returned
+// Trace has ParentID set and SpanID empty
+func ParseTraceparent(s string) (Trace, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return Trace{}, fmt.Errorf("invalid traceparent %q", s)
+	}
+	if !isHexID(parts[1], 32) || !isHexID(parts[2], 16) || !isHexID(parts[3], 2) {
+		return Trace{}, fmt.Errorf("invalid traceparent %q", s)
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	return Trace{TraceID: parts[1], ParentID: parts[2], Flags: flags[0]}, nil
+}
+
+// Traceparent return the traceparent header with SpanID as parent of outbound requests
+func (t Trace) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", t.TraceID, t.SpanID, t.Flags)
+}
+
+// IsValid return whether TraceID and SpanID are set
+func (t Trace) IsValid() bool {
+	return t.TraceID != "" && t.SpanID != ""
+}
+
+// isHexID check s is n lower hex and not all zero
+func isHexID(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	zero := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+		if c != '0' {
+			zero = false
+		}
+	}
+	return !zero || n == 2 // trace flags may be 00
+}
+
+// NewTraceID return random 128-bit trace id in hex
+func NewTraceID() string {
+	return randomHex(16)
+}
+
+// NewSpanID return random 64-bit span id in hex
+func NewSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// SetTrace store trace on ctx and the request context.Context, prefix the logger of ctx with the request id
+func (ctx *Context) SetTrace(t Trace) {
+	ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), traceKey{}, t))
+	logger := ctx.Logger
+	if tl, ok := logger.(*traceLogger); ok {
+		logger = tl.Logger
+	}
+	id := t.RequestID
+	if id == "" {
+		id = t.TraceID
+	}
+	ctx.Logger = &traceLogger{Logger: logger, prefix: "[" + strings.ReplaceAll(id, "%", "%%") + "] "}
+}
+
+// Trace return the trace stored by SetTrace, zero if none
+func (ctx *Context) Trace() Trace {
+	t, _ := TraceFromContext(ctx.Request.Context())
+	return t
+}
+
+// TraceFromContext return the trace stored by Context.SetTrace
+func TraceFromContext(c context.Context) (Trace, bool) {
+	t, ok := c.Value(traceKey{}).(Trace)
+	return t, ok
+}
+
+// InjectTrace set traceparent and tracestate of outbound req from the trace in c, and the legacy
+// request id header if given
+func InjectTrace(c context.Context, req *http.Request, header ...string) {
+	t, ok := TraceFromContext(c)
+	if !ok || !t.IsValid() {
+		return
+	}
+	req.Header.Set(HeaderTraceparent, t.Traceparent())
+	if t.State != "" {
+		req.Header.Set(HeaderTracestate, t.State)
+	}
+	if len(header) != 0 && t.RequestID != "" {
+		req.Header.Set(header[0], t.RequestID)
+	}
+}
+
+// TraceTransport propagate the trace in the context of every outbound request, base is
+// http.DefaultTransport if nil, e.g. http.Client{Transport: web.TraceTransport(nil)} with
+// client.Do(req.WithContext(ctx.Request.Context()))
+func TraceTransport(base http.RoundTripper, header ...string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if _, ok := TraceFromContext(req.Context()); ok {
+			req = req.Clone(req.Context())
+			InjectTrace(req.Context(), req, header...)
+		}
+		return base.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// traceLogger prefix every message with the request id
+type traceLogger struct {
+	log.Logger
+	prefix string
+}
+
+func (l *traceLogger) Debugf(format string, v ...interface{}) {
+	l.Logger.Debugf(l.prefix+format, v...)
+}
+
+func (l *traceLogger) Infof(format string, v ...interface{}) {
+	l.Logger.Infof(l.prefix+format, v...)
+}
+
+func (l *traceLogger) Warnf(format string, v ...interface{}) {
+	l.Logger.Warnf(l.prefix+format, v...)
+}
+
+func (l *traceLogger) Errorf(format string, v ...interface{}) {
+	l.Logger.Errorf(l.prefix+format, v...)
+}
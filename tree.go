@@ -0,0 +1,216 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Tree radix tree router, support static segments, `:param` and `*wildcard` patterns.
+// Priority is static > param > wildcard, independent of registration order.
+type Tree struct {
+	root     *node
+	patterns []string
+}
+
+type node struct {
+	prefix   string
+	static   []*node
+	param    *node
+	wildcard *node
+	name     string // param or wildcard name
+	entry    *Entry
+}
+
+// NewTree new radix tree router
+func NewTree() *Tree {
+	return &Tree{root: &node{}}
+}
+
+// Handle std http handle
+func (t *Tree) Handle(path string, handler http.Handler) {
+	t.Route(path, warpHandlerFunc(handler.ServeHTTP))
+}
+
+// HandleFunc handlefunc
+func (t *Tree) HandleFunc(path string, f http.HandlerFunc) {
+	t.Route(path, warpHandlerFunc(f))
+}
+
+// Route handle, panic if pattern is invalid or conflicts with a registered one
+func (t *Tree) Route(path string, handler Handler) {
+	t.add(newEntry(path, handler))
+}
+
+func (t *Tree) add(entry *Entry) {
+	if !strings.HasPrefix(entry.pattern, "/") {
+		panic(fmt.Sprintf("web: route %q must begin with '/'", entry.pattern))
+	}
+	t.root.insert(entry.pattern, entry.pattern, entry)
+	t.patterns = append(t.patterns, entry.pattern)
+}
+
+// RouteFunc route handlerFunc
+func (t *Tree) RouteFunc(path string, f HandlerFunc) {
+	t.Route(path, f)
+}
+
+// FindRoute find router
+func (t *Tree) FindRoute(path string) *Entry {
+	var params []string
+	return t.lookup(path, &params)
+}
+
+// FindRouteParams find router, return the entry and the captured params
+func (t *Tree) FindRouteParams(path string) (*Entry, map[string]string) {
+	var params []string
+	entry := t.lookup(path, &params)
+	if entry == nil {
+		return nil, nil
+	}
+	match := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		match[params[i]] = params[i+1]
+	}
+	return entry, match
+}
+
+// lookup find router, appending the names and values of the captured params to params
+func (t *Tree) lookup(path string, params *[]string) *Entry {
+	return t.root.find(path, params)
+}
+
+func (t *Tree) String() string {
+	patterns := append([]string(nil), t.patterns...)
+	sort.Strings(patterns)
+	return strings.Join(patterns, "\n")
+}
+
+func (n *node) insert(pattern, path string, entry *Entry) {
+	if path == "" {
+		if n.entry != nil {
+			panic(fmt.Sprintf("web: route %q conflicts with %q", pattern, n.entry.pattern))
+		}
+		n.entry = entry
+		return
+	}
+
+	switch path[0] {
+	case ':':
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		name := path[1:end]
+		if name == "" {
+			panic(fmt.Sprintf("web: route %q has empty param name", pattern))
+		}
+		if n.param == nil {
+			n.param = &node{name: name}
+		} else if n.param.name != name {
+			panic(fmt.Sprintf("web: route %q param :%s conflicts with :%s", pattern, name, n.param.name))
+		}
+		n.param.insert(pattern, path[end:], entry)
+		return
+	case '*':
+		name := path[1:]
+		if name == "" || strings.IndexByte(name, '/') >= 0 {
+			panic(fmt.Sprintf("web: route %q wildcard must be named and at the end", pattern))
+		}
+		if n.wildcard != nil {
+			panic(fmt.Sprintf("web: route %q conflicts with %q", pattern, n.wildcard.entry.pattern))
+		}
+		n.wildcard = &node{name: name, entry: entry}
+		return
+	}
+
+	end := strings.IndexAny(path, ":*")
+	if end < 0 {
+		end = len(path)
+	}
+	static := path[:end]
+	for _, child := range n.static {
+		if child.prefix[0] != static[0] {
+			continue
+		}
+		l := commonPrefix(child.prefix, static)
+		if l < len(child.prefix) {
+			child.split(l)
+		}
+		child.insert(pattern, path[l:], entry)
+		return
+	}
+	child := &node{prefix: static}
+	n.static = append(n.static, child)
+	child.insert(pattern, path[end:], entry)
+}
+
+// split move everything after prefix[:l] into a new child
+func (n *node) split(l int) {
+	child := &node{
+		prefix:   n.prefix[l:],
+		static:   n.static,
+		param:    n.param,
+		wildcard: n.wildcard,
+		entry:    n.entry,
+	}
+	n.prefix = n.prefix[:l]
+	n.static = []*node{child}
+	n.param = nil
+	n.wildcard = nil
+	n.entry = nil
+}
+
+func (n *node) find(path string, params *[]string) *Entry {
+	if path == "" {
+		if n.entry != nil {
+			return n.entry
+		}
+		if n.wildcard != nil {
+			*params = append(*params, n.wildcard.name, "")
+			return n.wildcard.entry
+		}
+		return nil
+	}
+
+	for _, child := range n.static {
+		if child.prefix[0] != path[0] {
+			continue
+		}
+		if strings.HasPrefix(path, child.prefix) {
+			if entry := child.find(path[len(child.prefix):], params); entry != nil {
+				return entry
+			}
+		}
+		break
+	}
+
+	if n.param != nil {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			*params = append(*params, n.param.name, path[:end])
+			if entry := n.param.find(path[end:], params); entry != nil {
+				return entry
+			}
+			*params = (*params)[:len(*params)-2]
+		}
+	}
+
+	if n.wildcard != nil {
+		*params = append(*params, n.wildcard.name, path)
+		return n.wildcard.entry
+	}
+	return nil
+}
+
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
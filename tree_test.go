@@ -0,0 +1,111 @@
+package web
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	tree := NewTree()
+	patterns := []string{
+		"/",
+		"/users",
+		"/users/new",
+		"/users/:id",
+		"/users/:id/files/*path",
+		"/userspace",
+		"/static/*filepath",
+		"/:lang/docs",
+	}
+	for _, pattern := range patterns {
+		tree.RouteFunc(pattern, func(*Context) {})
+	}
+
+	cases := []struct {
+		path    string
+		pattern string
+		params  map[string]string
+	}{
+		{"/", "/", map[string]string{}},
+		{"/users", "/users", map[string]string{}},
+		{"/users/new", "/users/new", map[string]string{}},
+		{"/users/42", "/users/:id", map[string]string{"id": "42"}},
+		{"/users/42/files/a/b.txt", "/users/:id/files/*path", map[string]string{"id": "42", "path": "a/b.txt"}},
+		{"/userspace", "/userspace", map[string]string{}},
+		{"/static/", "/static/*filepath", map[string]string{"filepath": ""}},
+		{"/users/docs", "/users/:id", map[string]string{"id": "docs"}},
+		{"/en/docs", "/:lang/docs", map[string]string{"lang": "en"}},
+		{"/users/42/other", "", nil},
+		{"/nothing", "", nil},
+	}
+	for _, c := range cases {
+		entry, params := tree.FindRouteParams(c.path)
+		if c.pattern == "" {
+			if entry != nil {
+				t.Errorf("%s: expect no match, got %s", c.path, entry.Pattern())
+			}
+			continue
+		}
+		if entry == nil || entry.Pattern() != c.pattern {
+			t.Errorf("%s: expect %s, got %v", c.path, c.pattern, entry)
+			continue
+		}
+		if fmt.Sprint(params) != fmt.Sprint(c.params) {
+			t.Errorf("%s: expect params %v, got %v", c.path, c.params, params)
+		}
+	}
+}
+
+func TestTreeConflict(t *testing.T) {
+	for _, patterns := range [][]string{
+		{"/users/:id", "/users/:name"},
+		{"/users", "/users"},
+		{"/files/*a", "/files/*b"},
+		{"/files/*a/b"},
+		{"users"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%v: expect panic", patterns)
+				}
+			}()
+			tree := NewTree()
+			for _, pattern := range patterns {
+				tree.RouteFunc(pattern, func(*Context) {})
+			}
+		}()
+	}
+}
+
+const benchRoutes = 300
+
+func BenchmarkTreeFindRoute(b *testing.B) {
+	tree := NewTree()
+	for i := 0; i < benchRoutes; i++ {
+		tree.RouteFunc(fmt.Sprintf("/api/v1/resource%d/:id", i), func(*Context) {})
+	}
+	path := fmt.Sprintf("/api/v1/resource%d/42", benchRoutes-1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if entry := tree.FindRoute(path); entry == nil {
+			b.Fatal("not found")
+		}
+	}
+}
+
+func BenchmarkMultiplexerFindRoute(b *testing.B) {
+	mux := NewMultiplexer()
+	for i := 0; i < benchRoutes; i++ {
+		mux.RouteFunc(fmt.Sprintf("^/api/v1/resource%d/(?P<id>[^/]+)$", i), func(*Context) {})
+	}
+	path := fmt.Sprintf("/api/v1/resource%d/42", benchRoutes-1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if entry := mux.FindRoute(path); entry == nil {
+			b.Fatal("not found")
+		}
+	}
+}
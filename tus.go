@@ -0,0 +1,502 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tus protocol version and extensions supported by TusHandler
+const (
+	TusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+	tusMediaType  = "application/offset+octet-stream"
+)
+
+// TusUpload state of a resumable upload
+type TusUpload struct {
+	ID        string            `json:"id"`
+	Size      int64             `json:"size"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at,omitempty"` // zero if never expires
+}
+
+// Complete return whether all bytes are received
+func (u TusUpload) Complete() bool {
+	return u.Offset == u.Size
+}
+
+// Expired return whether the incomplete upload expired at now
+func (u TusUpload) Expired(now time.Time) bool {
+	return !u.Complete() && !u.ExpiresAt.IsZero() && now.After(u.ExpiresAt)
+}
+
+// TusStore storage of resumable uploads. Get and Delete return an error wrapping os.ErrNotExist
+// for unknown ids. Append writes r at offset, which is the current offset of the upload, and
+// must keep the bytes written before an error of r, so the client can resume after them.
+type TusStore interface {
+	Create(upload TusUpload) error
+	Get(id string) (TusUpload, error)
+	Append(id string, offset int64, r io.Reader) (int64, error)
+	Delete(id string) error
+	List() ([]TusUpload, error)
+}
+
+// TusHandler Handler of the tus 1.0 resumable upload protocol with the creation, termination and
+// expiration extensions, routed with a parameter named by IDParam for upload ids, see Web.Tus
+//
+//	app.Tus("/files", web.NewTusHandler(web.NewTusFileStore("uploads")))
+//
+// Uploads are created by POST to the collection, the Location of the upload is the collection + id.
+type TusHandler struct {
+	BaseHandler
+	Store      TusStore
+	MaxSize    int64                                // bytes of an upload, unlimited if 0
+	Expiration time.Duration                        // incomplete uploads expire after it, never if 0
+	OnComplete func(ctx *Context, upload TusUpload) // called after the last byte is received
+	IDParam    string                               // route parameter of upload ids, "id" if empty
+
+	locks sync.Map // id -> *sync.Mutex, one PATCH per upload at a time until it completes or expires
+}
+
+// NewTusHandler new tus handler on store, uploads expire in 24 hours
+func NewTusHandler(store TusStore) *TusHandler {
+	return &TusHandler{Store: store, Expiration: 24 * time.Hour}
+}
+
+// Tus route h for the upload collection at prefix, like POST /files, and the uploads under it, like
+// PATCH /files/<id>
+func (s *Web) Tus(prefix string, h *TusHandler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	name := h.IDParam
+	if name == "" {
+		name = "id"
+	}
+	if prefix != "" {
+		s.Tree.add(newEntry(prefix, h))
+	}
+	s.Route(prefix+"/*"+name, h)
+}
+
+// Allow the methods of the tus protocol
+func (h *TusHandler) Allow() []string {
+	return []string{http.MethodOptions, http.MethodPost, http.MethodHead, http.MethodPatch, http.MethodDelete}
+}
+
+// Prepare check the protocol version of requests
+func (h *TusHandler) Prepare(ctx *Context) {
+	ctx.ResponseWriter.Header().Set("Tus-Resumable", TusVersion)
+	if ctx.Method != http.MethodOptions && ctx.Request.Header.Get("Tus-Resumable") != TusVersion {
+		ctx.ResponseWriter.Header().Set("Tus-Version", TusVersion)
+		ctx.Error(http.StatusPreconditionFailed)
+	}
+}
+
+// OPTIONS report the protocol version, extensions and max size
+func (h *TusHandler) OPTIONS(ctx *Context) {
+	header := ctx.ResponseWriter.Header()
+	header.Set("Tus-Version", TusVersion)
+	header.Set("Tus-Extension", tusExtensions)
+	if h.MaxSize > 0 {
+		header.Set("Tus-Max-Size", strconv.FormatInt(h.MaxSize, 10))
+	}
+	h.noContent(ctx)
+}
+
+// POST create an upload of Upload-Length bytes, or run PATCH and DELETE overridden by
+// X-HTTP-Method-Override
+func (h *TusHandler) POST(ctx *Context) {
+	switch ctx.Request.Header.Get("X-HTTP-Method-Override") {
+	case http.MethodPatch:
+		h.PATCH(ctx)
+		return
+	case http.MethodDelete:
+		h.DELETE(ctx)
+		return
+	}
+
+	size, err := strconv.ParseInt(ctx.Request.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		ctx.Error(http.StatusBadRequest)
+		return
+	}
+	if h.MaxSize > 0 && size > h.MaxSize {
+		ctx.Error(http.StatusRequestEntityTooLarge)
+		return
+	}
+	metadata, err := parseTusMetadata(ctx.Request.Header.Get("Upload-Metadata"))
+	if err != nil {
+		ctx.Error(http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	upload := TusUpload{ID: randomHex(16), Size: size, Metadata: metadata, CreatedAt: now}
+	if h.Expiration > 0 {
+		upload.ExpiresAt = now.Add(h.Expiration)
+	}
+	if err := h.Store.Create(upload); err != nil {
+		ctx.Logger.Errorf("tus create: %v", err)
+		ctx.Error(http.StatusInternalServerError)
+		return
+	}
+	ctx.ResponseWriter.Header().Set("Location", strings.TrimSuffix(ctx.URL.Path, "/")+"/"+upload.ID)
+	h.setExpires(ctx, upload)
+	ctx.SetStatusCode(http.StatusCreated)
+	ctx.ResponseWriter.WriteHeader(http.StatusCreated)
+	if upload.Complete() && h.OnComplete != nil {
+		h.OnComplete(ctx, upload)
+	}
+}
+
+// HEAD report the offset of an upload
+func (h *TusHandler) HEAD(ctx *Context) {
+	upload, ok := h.upload(ctx)
+	if !ok {
+		return
+	}
+	header := ctx.ResponseWriter.Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	header.Set("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	if len(upload.Metadata) != 0 {
+		header.Set("Upload-Metadata", formatTusMetadata(upload.Metadata))
+	}
+	h.setExpires(ctx, upload)
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.ResponseWriter.WriteHeader(http.StatusOK)
+}
+
+// PATCH append the body to an upload at Upload-Offset
+func (h *TusHandler) PATCH(ctx *Context) {
+	if ctx.Request.Header.Get("Content-Type") != tusMediaType {
+		ctx.Error(http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(ctx.Request.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		ctx.Error(http.StatusBadRequest)
+		return
+	}
+	id := h.id(ctx)
+	// look up before storing a lock, so requests for unknown ids leave none behind
+	if _, ok := h.upload(ctx); !ok {
+		return
+	}
+	lock, _ := h.locks.LoadOrStore(id, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	if !mu.TryLock() {
+		ctx.Error(http.StatusLocked)
+		return
+	}
+	defer mu.Unlock()
+
+	// again under the lock for the current offset, the upload may be deleted meanwhile
+	upload, ok := h.upload(ctx)
+	if !ok {
+		h.locks.Delete(id)
+		return
+	}
+	// the lock is not needed once the upload completes, later PATCHes conflict on the offset
+	defer func() {
+		if upload.Complete() {
+			h.locks.Delete(id)
+		}
+	}()
+	if offset != upload.Offset {
+		ctx.Error(http.StatusConflict)
+		return
+	}
+	remaining := upload.Size - offset
+	if ctx.Request.ContentLength > remaining {
+		ctx.Error(http.StatusRequestEntityTooLarge)
+		return
+	}
+	n, err := h.Store.Append(id, offset, &tusBodyReader{r: ctx.Request.Body, remaining: remaining})
+	upload.Offset += n
+	if errors.Is(err, errTusBodyTooLarge) {
+		// the bytes up to the length are kept, so the upload may be complete all the same
+		ctx.ResponseWriter.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		ctx.Error(http.StatusRequestEntityTooLarge)
+		if upload.Complete() && h.OnComplete != nil {
+			h.OnComplete(ctx, upload)
+		}
+		return
+	}
+	if err != nil {
+		ctx.Logger.Errorf("tus append %s at %d: %v", id, offset, err)
+		ctx.Error(http.StatusInternalServerError)
+		return
+	}
+	ctx.ResponseWriter.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	h.setExpires(ctx, upload)
+	h.noContent(ctx)
+	if upload.Complete() && h.OnComplete != nil {
+		h.OnComplete(ctx, upload)
+	}
+}
+
+// DELETE terminate an upload
+func (h *TusHandler) DELETE(ctx *Context) {
+	id := h.id(ctx)
+	if err := h.Store.Delete(id); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			ctx.Error(http.StatusNotFound)
+			return
+		}
+		ctx.Logger.Errorf("tus delete %s: %v", id, err)
+		ctx.Error(http.StatusInternalServerError)
+		return
+	}
+	h.locks.Delete(id)
+	h.noContent(ctx)
+}
+
+// Purge delete expired uploads, call it periodically
+func (h *TusHandler) Purge() error {
+	uploads, err := h.Store.List()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, upload := range uploads {
+		if upload.Expired(now) {
+			if err := h.Store.Delete(upload.ID); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			h.locks.Delete(upload.ID)
+		}
+	}
+	return nil
+}
+
+// errTusBodyTooLarge error of a PATCH body longer than the bytes remaining of the upload
+var errTusBodyTooLarge = errors.New("tus: body exceeds Upload-Length")
+
+// tusBodyReader read up to remaining bytes of a PATCH body, then fail if the body has more
+type tusBodyReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (r *tusBodyReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		var b [1]byte
+		if n, err := r.r.Read(b[:]); n > 0 {
+			return 0, errTusBodyTooLarge
+		} else if err != nil {
+			return 0, err
+		}
+		return 0, nil
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+func (h *TusHandler) id(ctx *Context) string {
+	name := h.IDParam
+	if name == "" {
+		name = "id"
+	}
+	return strings.Trim(ctx.Param(name), "/")
+}
+
+// upload get the upload of the request, respond 404 or 410 if not found or expired
+func (h *TusHandler) upload(ctx *Context) (TusUpload, bool) {
+	id := h.id(ctx)
+	upload, err := h.Store.Get(id)
+	if errors.Is(err, os.ErrNotExist) {
+		ctx.Error(http.StatusNotFound)
+		return upload, false
+	}
+	if err != nil {
+		ctx.Logger.Errorf("tus get %s: %v", id, err)
+		ctx.Error(http.StatusInternalServerError)
+		return upload, false
+	}
+	if upload.Expired(time.Now()) {
+		_ = h.Store.Delete(id)
+		h.locks.Delete(id)
+		ctx.Error(http.StatusGone)
+		return upload, false
+	}
+	return upload, true
+}
+
+func (h *TusHandler) setExpires(ctx *Context, upload TusUpload) {
+	if !upload.ExpiresAt.IsZero() && !upload.Complete() {
+		ctx.ResponseWriter.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func (h *TusHandler) noContent(ctx *Context) {
+	ctx.SetStatusCode(http.StatusNoContent)
+	ctx.ResponseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// parseTusMetadata parse Upload-Metadata `key base64value,key2,...`
+func parseTusMetadata(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("tus: invalid metadata %q", s)
+		}
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("tus: invalid metadata %q: %w", key, err)
+		}
+		metadata[key] = string(b)
+	}
+	return metadata, nil
+}
+
+func formatTusMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		if value == "" {
+			pairs = append(pairs, key)
+			continue
+		}
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// TusFileStore TusStore on local files, <id>.bin holds the bytes and <id>.info the state
+type TusFileStore struct {
+	Dir string
+}
+
+// NewTusFileStore new file store in dir, created if not exists
+func NewTusFileStore(dir string) *TusFileStore {
+	return &TusFileStore{Dir: dir}
+}
+
+// Path return the path of the bytes of upload id
+func (s *TusFileStore) Path(id string) string {
+	return filepath.Join(s.Dir, id+".bin")
+}
+
+func (s *TusFileStore) infoPath(id string) string {
+	return filepath.Join(s.Dir, id+".info")
+}
+
+// Create create the files of upload
+func (s *TusFileStore) Create(upload TusUpload) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.Path(upload.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return s.writeInfo(upload)
+}
+
+// Get return upload id, the offset is the size of the bytes file
+func (s *TusFileStore) Get(id string) (TusUpload, error) {
+	var upload TusUpload
+	if !isHexID(id, 32) {
+		return upload, fmt.Errorf("tus: upload %q: %w", id, os.ErrNotExist)
+	}
+	b, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		return upload, err
+	}
+	if err := json.Unmarshal(b, &upload); err != nil {
+		return upload, err
+	}
+	info, err := os.Stat(s.Path(id))
+	if err != nil {
+		return upload, err
+	}
+	upload.Offset = info.Size()
+	return upload, nil
+}
+
+// Append append r to the bytes file at offset
+func (s *TusFileStore) Append(id string, offset int64, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(s.Path(id), os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// Delete remove the files of upload id
+func (s *TusFileStore) Delete(id string) error {
+	if !isHexID(id, 32) {
+		return fmt.Errorf("tus: upload %q: %w", id, os.ErrNotExist)
+	}
+	if err := os.Remove(s.infoPath(id)); err != nil {
+		return err
+	}
+	if err := os.Remove(s.Path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List return all uploads
+func (s *TusFileStore) List() ([]TusUpload, error) {
+	names, err := filepath.Glob(filepath.Join(s.Dir, "*.info"))
+	if err != nil {
+		return nil, err
+	}
+	uploads := make([]TusUpload, 0, len(names))
+	for _, name := range names {
+		upload, err := s.Get(strings.TrimSuffix(filepath.Base(name), ".info"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, nil
+}
+
+// writeInfo write the state through a temp file
+func (s *TusFileStore) writeInfo(upload TusUpload) error {
+	b, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	tmp := s.infoPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.infoPath(upload.ID))
+}
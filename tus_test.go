@@ -0,0 +1,122 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corex-io/web"
+)
+
+func TestTus(t *testing.T) {
+	store := web.NewTusFileStore(t.TempDir())
+	tus := web.NewTusHandler(store)
+	tus.MaxSize = 100
+	var completed web.TusUpload
+	tus.OnComplete = func(ctx *web.Context, upload web.TusUpload) {
+		completed = upload
+	}
+	app := web.New(web.DisableAccessLog())
+	app.Tus("/files/", tus)
+
+	do := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Tus-Resumable", web.TusVersion)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodOptions, "/files/", "", nil)
+	if w.Code != http.StatusNoContent || w.Header().Get("Tus-Extension") != "creation,termination,expiration" || w.Header().Get("Tus-Max-Size") != "100" {
+		t.Errorf("unexpected options %d %v", w.Code, w.Header())
+	}
+	if w := do(http.MethodPost, "/files/", "", map[string]string{"Upload-Length": "101"}); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expect 413, got %d", w.Code)
+	}
+
+	w = do(http.MethodPost, "/files", "", map[string]string{"Upload-Length": "11", "Upload-Metadata": "filename aGVsbG8udHh0,private"})
+	location := w.Header().Get("Location")
+	if w.Code != http.StatusCreated || !strings.HasPrefix(location, "/files/") || w.Header().Get("Upload-Expires") == "" {
+		t.Fatalf("unexpected creation %d %v", w.Code, w.Header())
+	}
+
+	patch := func(offset, body string) *httptest.ResponseRecorder {
+		return do(http.MethodPatch, location, body, map[string]string{"Content-Type": "application/offset+octet-stream", "Upload-Offset": offset})
+	}
+	if w := patch("0", "hello "); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "6" {
+		t.Errorf("unexpected patch %d %v", w.Code, w.Header())
+	}
+	if w := patch("0", "again"); w.Code != http.StatusConflict {
+		t.Errorf("expect 409 on offset mismatch, got %d", w.Code)
+	}
+	w = do(http.MethodHead, location, "", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "6" || w.Header().Get("Upload-Length") != "11" ||
+		w.Header().Get("Upload-Metadata") != "filename aGVsbG8udHh0,private" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("unexpected head %d %v", w.Code, w.Header())
+	}
+	if w := patch("6", "world and more"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expect 413 for body longer than the upload, got %d", w.Code)
+	}
+	if w := do(http.MethodHead, location, "", nil); w.Header().Get("Upload-Offset") != "6" {
+		t.Errorf("body too large appended: %v", w.Header())
+	}
+	req := httptest.NewRequest(http.MethodPatch, location, strings.NewReader("world and more"))
+	req.ContentLength = -1
+	req.Header.Set("Tus-Resumable", web.TusVersion)
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", "6")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge || w.Header().Get("Upload-Offset") != "11" {
+		t.Errorf("expect 413 for chunked body longer than the upload, got %d %v", w.Code, w.Header())
+	}
+	if completed.ID == "" || completed.Metadata["filename"] != "hello.txt" {
+		t.Errorf("OnComplete not called with upload: %+v", completed)
+	}
+	if b, _ := os.ReadFile(store.Path(completed.ID)); string(b) != "hello world" {
+		t.Errorf("unexpected content %q", b)
+	}
+
+	// the id comes from the route parameter, whatever the shape of the path
+	app.Route("/uploads/:id/content", tus)
+	if w := do(http.MethodHead, "/uploads/"+completed.ID+"/content", "", nil); w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "11" {
+		t.Errorf("unexpected head by param %d %v", w.Code, w.Header())
+	}
+
+	if w := do(http.MethodDelete, location, "", nil); w.Code != http.StatusNoContent {
+		t.Errorf("unexpected delete %d", w.Code)
+	}
+	if w := do(http.MethodHead, location, "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expect 404 after delete, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/files/", nil)
+	req.Header.Set("Upload-Length", "1")
+	w = httptest.NewRecorder()
+	app.ServeHTTP(w, req)
+	if w.Code != http.StatusPreconditionFailed || w.Header().Get("Tus-Version") != web.TusVersion {
+		t.Errorf("expect 412 without Tus-Resumable, got %d", w.Code)
+	}
+
+	tus.Expiration = time.Nanosecond
+	location = do(http.MethodPost, "/files/", "", map[string]string{"Upload-Length": "5"}).Header().Get("Location")
+	time.Sleep(time.Millisecond)
+	if w := do(http.MethodHead, location, "", nil); w.Code != http.StatusGone {
+		t.Errorf("expect 410 for expired upload, got %d", w.Code)
+	}
+	do(http.MethodPost, "/files/", "", map[string]string{"Upload-Length": "5"})
+	time.Sleep(time.Millisecond)
+	if err := tus.Purge(); err != nil {
+		t.Fatal(err)
+	}
+	if uploads, err := store.List(); err != nil || len(uploads) != 0 {
+		t.Errorf("expired uploads not purged: %v %v", uploads, err)
+	}
+}
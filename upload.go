@@ -0,0 +1,394 @@
+package web
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxUploadValue bytes of a non-file multipart field kept in ctx.Form
+const maxUploadValue = 1 << 20
+
+// maxUploadSuffix numeric suffixes tried for a file name taken
+const maxUploadSuffix = 1000
+
+// Defaults of the non-file fields of Context.Upload
+const (
+	DefaultUploadMaxFields     = 1000
+	DefaultUploadMaxFieldsSize = 10 << 20
+)
+
+// UploadOptions options of Context.Upload
+type UploadOptions struct {
+	Dir           string                        // destination directory, created if not exists
+	Fields        []string                      // form fields of files accepted, all if empty
+	MaxFiles      int                           // number of files, unlimited if 0
+	MaxFileSize   int64                         // bytes of each file, unlimited if 0
+	MaxTotalSize  int64                         // bytes of all files and fields, unlimited if 0
+	MaxFields     int                           // number of non-file fields, DefaultUploadMaxFields by default
+	MaxFieldsSize int64                         // bytes of all non-file fields, DefaultUploadMaxFieldsSize by default
+	AllowTypes    []string                      // sniffed MIME types allowed, like "image/png" or "image/*", all if empty
+	Rename        func(name string) string      // name of the saved file from the sanitized client filename
+	Overwrite     bool                          // replace files of the same name instead of adding a numeric suffix
+	Progress      func(progress UploadProgress) // called as each file is written
+}
+
+// UploadOption func
+type UploadOption func(*UploadOptions)
+
+// UploadDir save files into dir
+func UploadDir(dir string) UploadOption {
+	return func(o *UploadOptions) {
+		o.Dir = dir
+	}
+}
+
+// UploadFields accept files of these form fields only
+func UploadFields(fields ...string) UploadOption {
+	return func(o *UploadOptions) {
+		o.Fields = append(o.Fields, fields...)
+	}
+}
+
+// UploadMaxFiles limit the number of files
+func UploadMaxFiles(n int) UploadOption {
+	return func(o *UploadOptions) {
+		o.MaxFiles = n
+	}
+}
+
+// UploadMaxFileSize limit bytes of each file
+func UploadMaxFileSize(size int64) UploadOption {
+	return func(o *UploadOptions) {
+		o.MaxFileSize = size
+	}
+}
+
+// UploadMaxTotalSize limit bytes of all files and fields
+func UploadMaxTotalSize(size int64) UploadOption {
+	return func(o *UploadOptions) {
+		o.MaxTotalSize = size
+	}
+}
+
+// UploadMaxFields limit the number of non-file fields
+func UploadMaxFields(n int) UploadOption {
+	return func(o *UploadOptions) {
+		o.MaxFields = n
+	}
+}
+
+// UploadMaxFieldsSize limit bytes of all non-file fields, each is kept in memory up to 1MB
+func UploadMaxFieldsSize(size int64) UploadOption {
+	return func(o *UploadOptions) {
+		o.MaxFieldsSize = size
+	}
+}
+
+// UploadAllowTypes allow files whose sniffed MIME type matches one of types, like "image/*"
+func UploadAllowTypes(types ...string) UploadOption {
+	return func(o *UploadOptions) {
+		o.AllowTypes = append(o.AllowTypes, types...)
+	}
+}
+
+// UploadRename name saved files by f, which gets the sanitized client filename
+func UploadRename(f func(name string) string) UploadOption {
+	return func(o *UploadOptions) {
+		o.Rename = f
+	}
+}
+
+// UploadOverwrite replace existing files of the same name, which are kept by default and the new
+// file is saved as name-1.ext, name-2.ext and so on
+func UploadOverwrite() UploadOption {
+	return func(o *UploadOptions) {
+		o.Overwrite = true
+	}
+}
+
+// UploadProgressFunc call f as each file is written
+func UploadProgressFunc(f func(progress UploadProgress)) UploadOption {
+	return func(o *UploadOptions) {
+		o.Progress = f
+	}
+}
+
+// UploadedFile a file saved by Context.Upload
+type UploadedFile struct {
+	Field       string // form field, empty for a raw body upload
+	Filename    string // sanitized client filename
+	Path        string // path of the saved file
+	Size        int64
+	ContentType string // sniffed MIME type
+}
+
+// UploadProgress progress of one file
+type UploadProgress struct {
+	Field    string
+	Filename string
+	Written  int64
+	Done     bool
+}
+
+// Upload stream the files of a multipart/form-data request, or the raw body named by
+// Content-Disposition filename, to Dir without buffering them in memory. Each file is written to
+// a temp file which is renamed when complete; if any file fails, the files saved are removed.
+// Errors are *HTTPError: 400 malformed, 413 too large or too many, 415 type not allowed.
+// Non-file fields of the multipart form are added to ctx.Form.
+func (ctx *Context) Upload(opts ...UploadOption) ([]UploadedFile, error) {
+	options := UploadOptions{Dir: ".", MaxFields: DefaultUploadMaxFields, MaxFieldsSize: DefaultUploadMaxFieldsSize}
+	for _, o := range opts {
+		o(&options)
+	}
+	if err := os.MkdirAll(options.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	u := uploader{opts: options}
+
+	mediaType, _, _ := mime.ParseMediaType(ctx.Request.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		_, params, _ := mime.ParseMediaType(ctx.Request.Header.Get("Content-Disposition"))
+		err := u.save("", params["filename"], ctx.Request.Body)
+		return u.finish(err)
+	}
+
+	reader, err := ctx.Request.MultipartReader()
+	if err != nil {
+		return nil, &HTTPError{Status: http.StatusBadRequest, Message: "invalid multipart form", Err: err}
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return u.finish(&HTTPError{Status: http.StatusBadRequest, Message: "invalid multipart form", Err: err})
+		}
+		if part.FileName() == "" {
+			err = u.addField(ctx.Request, part)
+		} else if u.accept(part.FormName()) {
+			err = u.save(part.FormName(), part.FileName(), part)
+		}
+		_ = part.Close()
+		if err != nil {
+			return u.finish(err)
+		}
+	}
+	return u.finish(nil)
+}
+
+type uploader struct {
+	opts       UploadOptions
+	files      []UploadedFile
+	total      int64
+	fields     int
+	fieldsSize int64
+}
+
+// addField add a non-file field to req.Form, counted against MaxFields, MaxFieldsSize and MaxTotalSize
+func (u *uploader) addField(req *http.Request, part *multipart.Part) error {
+	if u.fields >= u.opts.MaxFields {
+		return &HTTPError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("more than %d fields", u.opts.MaxFields)}
+	}
+	limit := int64(maxUploadValue)
+	if u.opts.MaxFieldsSize-u.fieldsSize < limit {
+		limit = u.opts.MaxFieldsSize - u.fieldsSize
+	}
+	if u.opts.MaxTotalSize > 0 && u.opts.MaxTotalSize-u.total < limit {
+		limit = u.opts.MaxTotalSize - u.total
+	}
+	b, err := io.ReadAll(io.LimitReader(part, limit+1))
+	if err != nil {
+		return &HTTPError{Status: http.StatusBadRequest, Message: "invalid multipart form", Err: err}
+	}
+	if int64(len(b)) > limit {
+		return &HTTPError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("field %s too large", part.FormName())}
+	}
+	u.fields++
+	u.fieldsSize += int64(len(b))
+	u.total += int64(len(b))
+	if req.Form == nil {
+		req.Form = make(map[string][]string)
+	}
+	req.Form.Add(part.FormName(), string(b))
+	return nil
+}
+
+func (u *uploader) accept(field string) bool {
+	if len(u.opts.Fields) == 0 {
+		return true
+	}
+	for _, f := range u.opts.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// finish return the files saved, or remove them on err
+func (u *uploader) finish(err error) ([]UploadedFile, error) {
+	if err == nil {
+		return u.files, nil
+	}
+	for _, f := range u.files {
+		_ = os.Remove(f.Path)
+	}
+	return nil, err
+}
+
+// save stream r to a temp file in Dir, then rename it to the sanitized name
+func (u *uploader) save(field, filename string, r io.Reader) error {
+	if u.opts.MaxFiles > 0 && len(u.files) >= u.opts.MaxFiles {
+		return &HTTPError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("more than %d files", u.opts.MaxFiles)}
+	}
+	name := SanitizeFilename(filename)
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return &HTTPError{Status: http.StatusBadRequest, Message: "read upload", Err: err}
+	}
+	contentType := http.DetectContentType(head)
+	if !allowType(u.opts.AllowTypes, contentType) {
+		return &HTTPError{Status: http.StatusUnsupportedMediaType, Message: fmt.Sprintf("type %s of %s not allowed", contentType, name)}
+	}
+
+	tmp, err := os.CreateTemp(u.opts.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	limit := int64(-1)
+	if u.opts.MaxFileSize > 0 {
+		limit = u.opts.MaxFileSize
+	}
+	if u.opts.MaxTotalSize > 0 && (limit < 0 || u.opts.MaxTotalSize-u.total < limit) {
+		limit = u.opts.MaxTotalSize - u.total
+	}
+	src := io.Reader(br)
+	if limit >= 0 {
+		src = io.LimitReader(br, limit+1)
+	}
+	w := &progressWriter{w: tmp, progress: UploadProgress{Field: field, Filename: name}, report: u.opts.Progress}
+	n, err := io.Copy(w, src)
+	if err != nil {
+		return &HTTPError{Status: http.StatusBadRequest, Message: "read upload", Err: err}
+	}
+	if limit >= 0 && n > limit {
+		return &HTTPError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("%s too large", name)}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	saved := name
+	if u.opts.Rename != nil {
+		saved = u.opts.Rename(name)
+	}
+	dst, err := u.place(tmp.Name(), SanitizeFilename(saved))
+	if err != nil {
+		return err
+	}
+	u.total += n
+	u.files = append(u.files, UploadedFile{Field: field, Filename: name, Path: dst, Size: n, ContentType: contentType})
+	if w.report != nil {
+		w.progress.Done = true
+		w.report(w.progress)
+	}
+	return nil
+}
+
+// place move tmp to name in Dir, or to the first free name-N.ext unless Overwrite
+func (u *uploader) place(tmp, name string) (string, error) {
+	dst := filepath.Join(u.opts.Dir, name)
+	if u.opts.Overwrite {
+		return dst, os.Rename(tmp, dst)
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; i <= maxUploadSuffix; i++ {
+		// reserve the name by an empty file, then replace it by tmp
+		f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_ = f.Close()
+			if err := os.Rename(tmp, dst); err != nil {
+				_ = os.Remove(dst)
+				return "", err
+			}
+			return dst, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+		dst = filepath.Join(u.opts.Dir, fmt.Sprintf("%s-%d%s", base, i, ext))
+	}
+	return "", &HTTPError{Status: http.StatusConflict, Message: fmt.Sprintf("%s exists", name)}
+}
+
+func allowType(allows []string, contentType string) bool {
+	if len(allows) == 0 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, allow := range allows {
+		if allow == mediaType || allow == "*/*" ||
+			(strings.HasSuffix(allow, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allow, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+type progressWriter struct {
+	w        io.Writer
+	progress UploadProgress
+	report   func(UploadProgress)
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.progress.Written += int64(n)
+	if w.report != nil {
+		w.report(w.progress)
+	}
+	return n, err
+}
+
+// SanitizeFilename return a safe base name from a client filename: path elements, control and
+// reserved characters and leading dots are removed, "file" if nothing is left
+func SanitizeFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"|?*`, r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	name = strings.TrimRight(name, ". ")
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
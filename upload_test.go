@@ -0,0 +1,152 @@
+package web_test
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/corex-io/web"
+)
+
+func multipartRequest(t *testing.T, files map[string]string, fields map[string]string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		_ = mw.WriteField(name, value)
+	}
+	for name, content := range files {
+		w, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func upload(req *http.Request, opts ...web.UploadOption) ([]web.UploadedFile, string, error) {
+	var files []web.UploadedFile
+	var form string
+	var err error
+	app := web.New(web.DisableAccessLog())
+	app.RouteFunc("/upload", func(ctx *web.Context) {
+		files, err = ctx.Upload(opts...)
+		form = ctx.FormValue("title")
+	})
+	app.ServeHTTP(httptest.NewRecorder(), req)
+	return files, form, err
+}
+
+func TestUpload(t *testing.T) {
+	dir := t.TempDir()
+	var progress []web.UploadProgress
+	req := multipartRequest(t, map[string]string{"../../etc/passwd": "hello"}, map[string]string{"title": "x"})
+	files, form, err := upload(req, web.UploadDir(dir), web.UploadProgressFunc(func(p web.UploadProgress) {
+		progress = append(progress, p)
+	}))
+	if err != nil || len(files) != 1 || form != "x" {
+		t.Fatalf("unexpected %v %q %v", files, form, err)
+	}
+	f := files[0]
+	if f.Path != filepath.Join(dir, "passwd") || f.Size != 5 || !strings.HasPrefix(f.ContentType, "text/plain") {
+		t.Errorf("unexpected file %+v", f)
+	}
+	if b, _ := os.ReadFile(f.Path); string(b) != "hello" {
+		t.Errorf("unexpected content %q", b)
+	}
+	if len(progress) == 0 || !progress[len(progress)-1].Done || progress[len(progress)-1].Written != 5 {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+
+	fields := map[string]string{"a": "12", "b": "34", "c": "56"}
+	for _, c := range []struct {
+		files  map[string]string
+		fields map[string]string
+		opts   []web.UploadOption
+		status int
+	}{
+		{map[string]string{"a.txt": "123456"}, nil, []web.UploadOption{web.UploadMaxFileSize(5)}, http.StatusRequestEntityTooLarge},
+		{map[string]string{"a.txt": "123", "b.txt": "456"}, nil, []web.UploadOption{web.UploadMaxTotalSize(5)}, http.StatusRequestEntityTooLarge},
+		{map[string]string{"a.txt": "123", "b.txt": "456"}, nil, []web.UploadOption{web.UploadMaxFiles(1)}, http.StatusRequestEntityTooLarge},
+		{map[string]string{"a.png": "not a png"}, nil, []web.UploadOption{web.UploadAllowTypes("image/*")}, http.StatusUnsupportedMediaType},
+		{map[string]string{"a.txt": "123"}, fields, []web.UploadOption{web.UploadMaxFields(2)}, http.StatusRequestEntityTooLarge},
+		{map[string]string{"a.txt": "123"}, fields, []web.UploadOption{web.UploadMaxFieldsSize(5)}, http.StatusRequestEntityTooLarge},
+		{map[string]string{"a.txt": "123"}, fields, []web.UploadOption{web.UploadMaxTotalSize(8)}, http.StatusRequestEntityTooLarge},
+	} {
+		dir := t.TempDir()
+		_, _, err := upload(multipartRequest(t, c.files, c.fields), append(c.opts, web.UploadDir(dir))...)
+		var httpErr *web.HTTPError
+		if !errors.As(err, &httpErr) || httpErr.Status != c.status {
+			t.Errorf("%v: expect %d, got %v", c.files, c.status, err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("%v: files left after failure: %v", c.files, entries)
+		}
+	}
+
+	for i, expect := range []string{"a.txt", "a-1.txt", "a-2.txt"} {
+		files, _, err := upload(multipartRequest(t, map[string]string{"a.txt": expect}, nil), web.UploadDir(dir))
+		if err != nil || files[0].Path != filepath.Join(dir, expect) {
+			t.Fatalf("upload %d: unexpected %+v %v", i, files, err)
+		}
+	}
+	files, _, err = upload(multipartRequest(t, map[string]string{"a.txt": "new"}, nil), web.UploadDir(dir), web.UploadOverwrite())
+	if err != nil || files[0].Path != filepath.Join(dir, "a.txt") {
+		t.Fatalf("unexpected overwrite %+v %v", files, err)
+	}
+	for name, expect := range map[string]string{"a.txt": "new", "a-1.txt": "a-1.txt", "a-2.txt": "a-2.txt"} {
+		if b, _ := os.ReadFile(filepath.Join(dir, name)); string(b) != expect {
+			t.Errorf("%s: unexpected content %q", name, b)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/upload", strings.NewReader("\x89PNG\r\n\x1a\n"))
+	req.Header.Set("Content-Disposition", `attachment; filename="..\\logo.png"`)
+	files, _, err = upload(req, web.UploadDir(dir), web.UploadAllowTypes("image/png"))
+	if err != nil || len(files) != 1 || files[0].Path != filepath.Join(dir, "logo.png") || files[0].ContentType != "image/png" {
+		t.Errorf("unexpected raw upload %+v %v", files, err)
+	}
+}
+
+func TestRecvFile2(t *testing.T) {
+	dir := t.TempDir()
+	app := web.New(web.DisableAccessLog())
+	app.RouteFunc("/upload", func(ctx *web.Context) {
+		if err := ctx.RecvFile2(ctx.FormValue("name"), dir); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, c := range []struct{ query, saved string }{{"", "sent.txt"}, {"?name=kept.txt", "kept.txt"}} {
+		req := httptest.NewRequest(http.MethodPut, "/upload"+c.query, strings.NewReader("body"))
+		req.Header.Set("Content-Disposition", `attachment; filename="sent.txt"`)
+		app.ServeHTTP(httptest.NewRecorder(), req)
+		if b, err := os.ReadFile(filepath.Join(dir, c.saved)); err != nil || string(b) != "body" {
+			t.Errorf("%q: expect %s saved, got %q %v", c.query, c.saved, b, err)
+		}
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	for name, expect := range map[string]string{
+		"../../etc/passwd":  "passwd",
+		`C:\Users\a\b.txt`:  "b.txt",
+		"..":                "file",
+		".htaccess":         "htaccess",
+		"a\x00b<c>.txt ":    "abc.txt",
+		"":                  "file",
+		"report 2024.pdf..": "report 2024.pdf",
+	} {
+		if got := web.SanitizeFilename(name); got != expect {
+			t.Errorf("SanitizeFilename(%q) = %q, expect %q", name, got, expect)
+		}
+	}
+}
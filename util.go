@@ -45,3 +45,59 @@ func toHTTPError(err error) int {
 	}
 	return http.StatusInternalServerError
 }
+
+// isRegexPattern route pattern beginning with `^`, ending with `$`, or using regex syntax the radix
+// tree doesn't, like "/api/.*" or "/users/[0-9]+", is a regex, others may be radix tree patterns.
+// `.` alone is literal, so "/robots.txt" may be a tree pattern.
+func isRegexPattern(path string) bool {
+	if strings.HasPrefix(path, "^") || strings.HasSuffix(path, "$") ||
+		strings.ContainsAny(path, `()[]{}|\+?`) || strings.Contains(path, ".*") {
+		return true
+	}
+	for i := 0; i < len(path); i++ {
+		// `*name` of a whole path segment is a tree wildcard, other `*` are regex quantifiers
+		if path[i] == '*' && (i == 0 || path[i-1] != '/' || i+1 == len(path) || path[i+1] == '/') {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTreeParams return whether a path segment of pattern is a `:param` or `*wildcard`
+func hasTreeParams(pattern string) bool {
+	for _, segment := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// joinErrors join the non-nil errs into one with a message per line, nil if none
+func joinErrors(errs ...error) error {
+	var joined multiError
+	for _, err := range errs {
+		if err != nil {
+			joined = append(joined, err)
+		}
+	}
+	if len(joined) == 0 {
+		return nil
+	}
+	return joined
+}
+
+type multiError []error
+
+func (e multiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap return the joined errors for errors.Is and errors.As
+func (e multiError) Unwrap() []error {
+	return e
+}
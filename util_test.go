@@ -13,3 +13,37 @@ func TestIPNet(t *testing.T) {
 	ok := contains("127.0.0.1", ipNet)
 	t.Log(ok)
 }
+
+func TestIsRegexPattern(t *testing.T) {
+	for pattern, expect := range map[string]bool{
+		"/users/:id":          false,
+		"/static/*filepath":   false,
+		"/robots.txt":         false,
+		"/v1.0/items":         false,
+		"^/mytest$":           true,
+		"/api/.*":             true,
+		"/users/[0-9]+":       true,
+		"/(?P<name>[a-z]+)":   true,
+		"/files/*":            true,
+		"/a*b":                true,
+		"/health$":            true,
+		"/users/:id/files/*p": false,
+	} {
+		if got := isRegexPattern(pattern); got != expect {
+			t.Errorf("isRegexPattern(%q) = %v, expect %v", pattern, got, expect)
+		}
+	}
+}
+
+func TestHasTreeParams(t *testing.T) {
+	for pattern, expect := range map[string]bool{
+		"/users/:id":        true,
+		"/static/*filepath": true,
+		"/api/":             false,
+		"/a:b":              false,
+	} {
+		if got := hasTreeParams(pattern); got != expect {
+			t.Errorf("hasTreeParams(%q) = %v, expect %v", pattern, got, expect)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"fmt"
+	"time"
+)
+
+// Set store a request-scoped value, for middleware to pass data like user or tenant to handlers
+func (ctx *Context) Set(key string, v interface{}) {
+	ctx.checkReleased()
+	if ctx.values == nil {
+		ctx.values = make(map[string]interface{})
+	}
+	ctx.values[key] = v
+}
+
+// Get return the value stored by Set
+func (ctx *Context) Get(key string) (interface{}, bool) {
+	ctx.checkReleased()
+	v, ok := ctx.values[key]
+	return v, ok
+}
+
+// MustGet return the value stored by Set, panic if not exists
+func (ctx *Context) MustGet(key string) interface{} {
+	v, ok := ctx.values[key]
+	if !ok {
+		panic(fmt.Sprintf("web: key %q not set", key))
+	}
+	return v
+}
+
+// GetString return the string stored by Set, empty if not exists or not a string
+func (ctx *Context) GetString(key string) string {
+	v, _ := ctx.values[key].(string)
+	return v
+}
+
+// GetInt return the int stored by Set, 0 if not exists or not an int
+func (ctx *Context) GetInt(key string) int {
+	v, _ := ctx.values[key].(int)
+	return v
+}
+
+// GetInt64 return the int64 stored by Set, 0 if not exists or not an int64
+func (ctx *Context) GetInt64(key string) int64 {
+	v, _ := ctx.values[key].(int64)
+	return v
+}
+
+// GetBool return the bool stored by Set, false if not exists or not a bool
+func (ctx *Context) GetBool(key string) bool {
+	v, _ := ctx.values[key].(bool)
+	return v
+}
+
+// Deadline context.Context, *Context can be passed where a context.Context is needed
+func (ctx *Context) Deadline() (time.Time, bool) {
+	return ctx.Request.Context().Deadline()
+}
+
+// Done context.Context, closed when the client goes away or the request times out
+func (ctx *Context) Done() <-chan struct{} {
+	return ctx.Request.Context().Done()
+}
+
+// Err context.Context
+func (ctx *Context) Err() error {
+	return ctx.Request.Context().Err()
+}
+
+// Value context.Context, string keys look up values stored by Set first
+func (ctx *Context) Value(key interface{}) interface{} {
+	if k, ok := key.(string); ok {
+		if v, ok := ctx.values[k]; ok {
+			return v
+		}
+	}
+	return ctx.Request.Context().Value(key)
+}
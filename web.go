@@ -3,17 +3,18 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
-	"path/filepath"
 	"runtime/debug"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/corex-io/log"
-	"golang.org/x/net/http2"
 )
 
 // Web service
@@ -22,22 +23,49 @@ type Web struct {
 	Log  log.Logger
 	Mids []func(*Context)
 	Mux  Multiplexer
+	Tree *Tree
+	// Envelope wrap the response of Context.JSON, DefaultEnvelope by default
+	Envelope EnvelopeFunc
+	// ErrorHandler respond errors returned by HandlerFuncE, DefaultErrorHandler by default
+	ErrorHandler ErrorHandlerFunc
+	// renderers of Context.Negotiate, see AddRenderer
+	renderers []renderer
+	// templates of Context.HTML, see LoadTemplates
+	templates *Templates
+	// statics []staticPath of Options.StaticPaths, built on first request
+	statics atomic.Value
+	// handler s.handle bound once, rather than on every request
+	handler func(*Context)
 	*http.Server
 	sync.Pool
+
+	mu         sync.Mutex
+	servers    []*http.Server
+	reloaders  []*certReloader
+	metrics    *Metrics
+	ready      int32
+	inflight   int64
+	onShutdown []func()
 }
 
 // New new service
 func New(opts ...Option) *Web {
 	options := newOptions(opts...)
 	web := Web{
-		opts: options,
-		Log:  log.DefaultStdLog(),
-		Mux:  NewMultiplexer(),
+		opts:         options,
+		Log:          log.DefaultStdLog(),
+		Mux:          NewMultiplexer(),
+		Tree:         NewTree(),
+		Envelope:     DefaultEnvelope,
+		ErrorHandler: DefaultErrorHandler,
+		renderers:    defaultRenderers(),
+		Pool:         sync.Pool{New: newContext},
 	}
+	web.handler = web.handle
 	return &web
 }
 
-// Use append middleware
+// Use append middleware, middleware may call ctx.Next to wrap the handler
 func (s *Web) Use(f ...func(*Context)) {
 	s.Mids = append(s.Mids, f...)
 }
@@ -48,6 +76,7 @@ func (s *Web) Init(opts ...Option) {
 	for _, o := range opts {
 		o(&s.opts)
 	}
+	s.statics.Store([]staticPath(nil))
 }
 
 // SetLog set log
@@ -61,49 +90,166 @@ func (s *Web) Load(v interface{}) error {
 	if err != nil {
 		return err
 	}
+	s.statics.Store([]staticPath(nil))
 	return json.Unmarshal(b, &s.opts)
 }
 
-// Run run
+// Run run until ctx is done, then shutdown gracefully: readiness turns unhealthy,
+// in-flight requests are drained and OnShutdown hooks are called.
+// Every listener of Options is served by its own http.Server sharing the same handler,
+// if one stops unexpectedly the others are drained too.
+// It returns nil on orderly stop.
 func (s *Web) Run(ctx context.Context) error {
-	s.Server = &http.Server{
-		Addr:    s.opts.Address,
-		Handler: s,
-		// TLSConfig:         nil,
-		ReadTimeout:       time.Duration(s.opts.ReadTimeout) * time.Second,
-		ReadHeaderTimeout: time.Duration(s.opts.ReadHeaderTimeout) * time.Second,
-		WriteTimeout:      time.Duration(s.opts.WriteTimeout) * time.Second,
-		IdleTimeout:       time.Duration(s.opts.IdleTimeout) * time.Second,
-		MaxHeaderBytes:    s.opts.MaxHeaderBytes,
+	listeners := s.opts.listeners()
+	lns := make([]net.Listener, 0, len(listeners))
+	servers := make([]*http.Server, 0, len(listeners))
+	s.mu.Lock()
+	s.reloaders = nil
+	s.mu.Unlock()
+	for _, l := range listeners {
+		server, err := s.newServer(l)
+		if err == nil {
+			var ln net.Listener
+			if ln, err = l.listen(); err == nil {
+				lns = append(lns, ln)
+				servers = append(servers, server)
+				continue
+			}
+		}
+		for _, ln := range lns {
+			_ = ln.Close()
+		}
+		return fmt.Errorf("listen %s: %w", l, err)
+	}
+	s.mu.Lock()
+	s.servers = servers
+	s.Server = servers[0]
+	s.mu.Unlock()
+	if len(s.reloaders) != 0 && (s.opts.ReloadOnSIGHUP || s.opts.CertReloadInterval > 0) {
+		stop := s.watchCerts()
+		defer stop()
+	}
+
+	errCh := make(chan error, len(lns))
+	for i, ln := range lns {
+		go func(l Listener, server *http.Server, ln net.Listener) {
+			s.Log.Debugf("http serve [%s]...", l)
+			if l.isTLS() {
+				// certificates are provided by TLSConfig to support reloading
+				errCh <- server.ServeTLS(ln, "", "")
+				return
+			}
+			errCh <- server.Serve(ln)
+		}(listeners[i], servers[i], ln)
+	}
+	atomic.StoreInt32(&s.ready, 1)
+
+	var err error
+	running := len(lns)
+	select {
+	case err = <-errCh:
+		running--
+		if err == http.ErrServerClosed {
+			err = nil
+		} else {
+			s.Log.Errorf("serve: %v", err)
+		}
+		atomic.StoreInt32(&s.ready, 0)
+		_ = s.drain()
+	case <-ctx.Done():
+		err = s.shutdown()
 	}
 
-	serverhttp2 := &http2.Server{
-		IdleTimeout: time.Duration(s.opts.IdleTimeout) * time.Second,
+	for ; running > 0; running-- {
+		if serveErr := <-errCh; serveErr != http.ErrServerClosed && err == nil {
+			err = serveErr
+		}
 	}
-	if err := http2.ConfigureServer(s.Server, serverhttp2); err != nil {
-		s.Log.Errorf("%v", err)
+	s.runShutdownHooks()
+	return err
+}
+
+// shutdown turn readiness unhealthy, wait ShutdownDelay, then drain in-flight requests
+func (s *Web) shutdown() error {
+	atomic.StoreInt32(&s.ready, 0)
+	if s.opts.ShutdownDelay > 0 {
+		s.Log.Infof("readiness unhealthy, wait %ds before draining", s.opts.ShutdownDelay)
+		time.Sleep(time.Duration(s.opts.ShutdownDelay) * time.Second)
 	}
-	s.Pool.New = func() interface{} {
-		return &Context{}
+	return s.drain()
+}
+
+// drain shutdown every server for at most DrainTimeout, connections still active after that are closed.
+func (s *Web) drain() error {
+	ctx := context.Background()
+	if s.opts.DrainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.opts.DrainTimeout)*time.Second)
+		defer cancel()
 	}
-	s.Log.Debugf("http serve [%s]...", s.opts.Address)
+	s.Log.Infof("draining %d in-flight requests", s.InFlight())
 
-	go func(ctx context.Context) {
-		select {
-		case <-ctx.Done():
-			_ = s.Shutdown(ctx)
+	s.mu.Lock()
+	servers := s.servers
+	s.mu.Unlock()
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			errs <- server.Shutdown(ctx)
+		}(server)
+	}
+	var err error
+	for range servers {
+		if e := <-errs; e != nil && err == nil {
+			err = e
 		}
-	}(ctx)
+	}
+	if err != nil {
+		s.Log.Warnf("drain timeout, %d requests cut off: %v", s.InFlight(), err)
+		for _, server := range servers {
+			_ = server.Close()
+		}
+	}
+	return err
+}
+
+// OnShutdown register hooks called after the server stopped and in-flight requests are drained
+func (s *Web) OnShutdown(f ...func()) {
+	s.onShutdown = append(s.onShutdown, f...)
+}
 
-	if s.opts.CertFile != "" && s.opts.KeyFile != "" {
-		return s.Server.ListenAndServeTLS(s.opts.CertFile, s.opts.KeyFile)
+func (s *Web) runShutdownHooks() {
+	for _, f := range s.onShutdown {
+		f()
 	}
-	return s.Server.ListenAndServe()
 }
 
-// Close close
+// Ready return whether the server is serving and not shutting down
+func (s *Web) Ready() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+// InFlight return the number of requests being served
+func (s *Web) InFlight() int64 {
+	return atomic.LoadInt64(&s.inflight)
+}
+
+// HealthCheck route a readiness probe on path, response 503 once shutdown begins
+func (s *Web) HealthCheck(path string) {
+	s.RouteFunc(path, func(ctx *Context) {
+		if !s.Ready() {
+			ctx.Error(http.StatusServiceUnavailable)
+			return
+		}
+		ctx.Text([]byte("ok"))
+	})
+}
+
+// Close close every server, waiting at most DrainTimeout for in-flight requests like Run does on
+// cancel, Run returns once they are done
 func (s *Web) Close() error {
-	return s.Shutdown(context.Background())
+	atomic.StoreInt32(&s.ready, 0)
+	return s.drain()
 }
 
 func (s *Web) String() string {
@@ -112,46 +258,122 @@ func (s *Web) String() string {
 
 // Handle http handle
 func (s *Web) Handle(path string, handler http.Handler) {
-	s.Mux.Handle(path, handler)
+	s.Route(path, warpHandlerFunc(handler.ServeHTTP))
 }
 
 // HandleFunc http Handle func
 func (s *Web) HandleFunc(path string, f http.HandlerFunc) {
-	s.Mux.HandleFunc(path, f)
+	s.Route(path, warpHandlerFunc(f))
 }
 
 // HandleFs filesystem
 func (s *Web) HandleFs(srtipPath, path string) {
-	s.Handle(srtipPath, http.StripPrefix(strings.Trim(srtipPath, "^$"), http.FileServer(http.Dir(path))))
+	prefix := strings.Trim(srtipPath, "^$")
+	handler := http.StripPrefix(prefix, NewFileServer(os.DirFS(path)))
+	if !s.isTreePattern(srtipPath, false) {
+		s.Handle(srtipPath, handler)
+		return
+	}
+	s.Handle(strings.TrimSuffix(prefix, "/")+"/*filepath", handler)
 }
 
-// Route handle
+// Route handle, patterns with `:param` or `*wildcard` segments like `/users/:id` or `/static/*filepath`
+// are radix tree patterns matched by Tree first, plain paths too with TreeRouting or in a Group. Others
+// are regexes matched by Mux in registration order, unanchored unless beginning with `^` or ending
+// with `$`, so "/api/" serves any path containing it.
 func (s *Web) Route(path string, handle Handler) {
-	s.Mux.Route(path, handle)
+	s.route(path, handle, nil)
+}
+
+func (s *Web) route(path string, handle Handler, group *Group) {
+	entry := newEntry(path, handle)
+	entry.group = group
+	if !s.isTreePattern(path, group != nil) {
+		s.Mux.add(entry)
+		return
+	}
+	s.Tree.add(entry)
+}
+
+// isTreePattern return whether pattern is routed by Tree, plain paths only if exact
+func (s *Web) isTreePattern(pattern string, exact bool) bool {
+	if isRegexPattern(pattern) {
+		return false
+	}
+	return exact || s.opts.TreeRouting || hasTreeParams(pattern)
 }
 
 // RouteFunc route handlerfunc
 func (s *Web) RouteFunc(path string, f HandlerFunc) {
-	s.Mux.RouteFunc(path, f)
+	s.Route(path, f)
 }
 
-func (s *Web) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
-	ctx := &Context{
-		Request:        req,
-		ResponseWriter: resp,
-		Timestamp:      time.Now(),
-		Logger:         s.Log,
+// FindRoute find router in Tree, then in Mux
+func (s *Web) FindRoute(path string) *Entry {
+	if entry := s.Tree.FindRoute(path); entry != nil {
+		return entry
+	}
+	return s.Mux.FindRoute(path)
+}
+
+// FindRouteParams find router in Tree, then in Mux, return the entry and the captured params
+func (s *Web) FindRouteParams(path string) (*Entry, map[string]string) {
+	if entry, params := s.Tree.FindRouteParams(path); entry != nil {
+		return entry, params
 	}
+	return s.Mux.FindRouteParams(path)
+}
 
+// findRoute find router in Tree, then in Mux, storing the captured params into ctx
+func (s *Web) findRoute(ctx *Context, path string) *Entry {
+	ctx.paramBuf = ctx.paramBuf[:0]
+	if entry := s.Tree.lookup(path, &ctx.paramBuf); entry != nil {
+		for i := 0; i < len(ctx.paramBuf); i += 2 {
+			ctx.setParam(ctx.paramBuf[i], ctx.paramBuf[i+1])
+		}
+		return entry
+	}
+	entry, params := s.Mux.FindRouteParams(path)
+	for k, v := range params {
+		ctx.setParam(k, v)
+	}
+	return entry
+}
+
+func (s *Web) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	atomic.AddInt64(&s.inflight, 1)
+	defer atomic.AddInt64(&s.inflight, -1)
+
+	ctx := s.acquire(resp, req, time.Now())
+
+	if s.metrics != nil {
+		s.metrics.begin()
+	}
 	defer func(ctx *Context) {
-		if err := recover(); err != nil {
-			ctx.Error(http.StatusInternalServerError)
-			s.Log.Errorf("%v, %v", string(debug.Stack()), err)
+		err := recover()
+		// panic recorded by a recovery middleware reaching here was panicked again on purpose
+		repanic := err == http.ErrAbortHandler || (err != nil && ctx.panic != nil)
+		if err != nil && !repanic {
+			ctx.SetPanic(err)
+			if !ctx.Written() {
+				ctx.Error(http.StatusInternalServerError)
+			}
+			ctx.Logger.Errorf("%v, %v", string(debug.Stack()), err)
+		}
+		if s.metrics != nil {
+			s.metrics.end(ctx)
 		}
-		if ctx.statusCode == 0 {
-			ctx.statusCode = http.StatusOK
+		if repanic {
+			panic(err)
 		}
-		s.Log.Infof("%s %d %s (%s) %s", req.Method, ctx.statusCode, ctx.URL.String(), ctx.Remote(), time.Since(ctx.Timestamp))
+		if !s.opts.DisableAccessLog {
+			status := ctx.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			ctx.Logger.Infof("%s %d %s (%s) %s", req.Method, status, ctx.URL.String(), ctx.Remote(), time.Since(ctx.Timestamp))
+		}
+		s.release(ctx)
 	}(ctx)
 
 	if err := ctx.ParseForm(); err != nil {
@@ -159,30 +381,40 @@ func (s *Web) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	for _, mid := range s.Mids {
-		mid(ctx)
-		if ctx.IsFinish() {
-			return
-		}
-	}
-	// handler static file
-	for staticPath, webPath := range s.opts.StaticPaths {
-		if filepath.HasPrefix(ctx.URL.Path, staticPath) {
-			file := filepath.Join(webPath, strings.TrimPrefix(ctx.URL.Path, staticPath))
-			if _, err := os.Stat(file); os.IsNotExist(err) {
-				ctx.statusCode = http.StatusNotFound
-			}
-			http.ServeFile(ctx.ResponseWriter, ctx.Request, file)
+	ctx.handlers = append(ctx.handlers, s.Mids...)
+	ctx.handlers = append(ctx.handlers, s.handler)
+	ctx.Next()
+}
+
+// handle the innermost middleware, serve static file or the matched route
+func (s *Web) handle(ctx *Context) {
+	for _, static := range s.staticPaths() {
+		if strings.HasPrefix(ctx.URL.Path, static.prefix) {
+			ctx.static = true
+			http.StripPrefix(static.prefix, static.server).ServeHTTP(ctx.ResponseWriter, ctx.Request)
 			return
 		}
 	}
 
-	entry := s.Mux.FindRoute(ctx.URL.Path)
+	entry := s.findRoute(ctx, ctx.URL.Path)
 	if entry == nil {
 		ctx.Error(http.StatusNotFound)
 		return
 	}
+	ctx.entry = entry
 
+	if s.opts.RequestTimeout > 0 {
+		ctx.handlers = append(ctx.handlers, Timeout(time.Duration(s.opts.RequestTimeout)*time.Second))
+	}
+	if entry.group != nil {
+		ctx.handlers = append(ctx.handlers, entry.group.middlewares()...)
+	}
+	ctx.handlers = append(ctx.handlers, serveEntry)
+}
+
+// serveEntry run the Init/Prepare/METHOD/Finish lifecycle of the matched handler
+func serveEntry(ctx *Context) {
+	entry := ctx.entry
 	entry.MyInterface.Init(ctx)
 	if ctx.IsFinish() {
 		return
@@ -192,7 +424,33 @@ func (s *Web) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	if ctx.IsFinish() {
 		return
 	}
-	switch ctx.Method {
+	dispatch(ctx, entry)
+	if ctx.IsFinish() {
+		return
+	}
+	entry.MyInterface.Finish(ctx)
+}
+
+// dispatch call the handler method of ctx.Method, answer OPTIONS, HEAD and 405 for methods handler not implements
+func dispatch(ctx *Context, entry *Entry) {
+	method := ctx.Method
+	if !entry.implements[method] {
+		switch {
+		case method == http.MethodOptions:
+			ctx.ResponseWriter.Header().Set("Allow", entry.allow)
+			ctx.SetStatusCode(http.StatusNoContent)
+			ctx.ResponseWriter.WriteHeader(http.StatusNoContent)
+			return
+		case method == http.MethodHead && entry.implements[http.MethodGet]:
+			// net/http discards the body of HEAD response
+			method = http.MethodGet
+		default:
+			ctx.ResponseWriter.Header().Set("Allow", entry.allow)
+			ctx.Error(http.StatusMethodNotAllowed)
+			return
+		}
+	}
+	switch method {
 	case http.MethodConnect:
 		entry.MyInterface.CONNECT(ctx)
 	case http.MethodOptions:
@@ -212,16 +470,12 @@ func (s *Web) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	case http.MethodTrace:
 		entry.MyInterface.TRACE(ctx)
 	}
-	if ctx.IsFinish() {
-		return
-	}
-	entry.MyInterface.Finish(ctx)
 }
 
 // DebugPprof debugPprof
 func (s *Web) DebugPprof() {
 	s.HandleFunc("^/_routeList$", func(resp http.ResponseWriter, req *http.Request) {
-		resp.Write([]byte(s.Mux.String()))
+		resp.Write([]byte(s.Tree.String() + "\n" + s.Mux.String()))
 	})
 	s.HandleFunc("^/debug/pprof/$", pprof.Index)
 	s.HandleFunc("^/debug/pprof/allocs$", pprof.Index)
@@ -1,8 +1,12 @@
 package web_test
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -52,5 +56,182 @@ func TestWeb(t *testing.T) {
 	app.Route("^/upload", &Upload{})
 	app.Use(middleware.Trace(), middleware.AccessIP("127.0.0.1/32"))
 	app.Init()
-	app.Run()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	app.Run(ctx)
+}
+
+func freeAddress(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+func waitReady(t *testing.T, url string) {
+	for i := 0; i < 100; i++ {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s not ready", url)
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	addr := freeAddress(t)
+	app := web.New(web.Address(addr), web.ShutdownDelay(1), web.DrainTimeout(5))
+	app.HealthCheck("/healthz")
+	started := make(chan struct{})
+	app.RouteFunc("/slow", func(ctx *web.Context) {
+		close(started)
+		time.Sleep(500 * time.Millisecond)
+		ctx.Text([]byte("done"))
+	})
+	var hooked int32
+	app.OnShutdown(func() { atomic.StoreInt32(&hooked, 1) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run(ctx) }()
+	waitReady(t, "http://"+addr+"/healthz")
+
+	slow := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			slow <- 0
+			return
+		}
+		resp.Body.Close()
+		slow <- resp.StatusCode
+	}()
+	<-started
+	if app.InFlight() != 1 {
+		t.Errorf("expect 1 in-flight request, got %d", app.InFlight())
+	}
+	cancel()
+
+	time.Sleep(100 * time.Millisecond)
+	resp, err := http.Get("http://" + addr + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expect unhealthy while shutting down, got %d", resp.StatusCode)
+	}
+
+	if code := <-slow; code != http.StatusOK {
+		t.Errorf("expect slow request drained with 200, got %d", code)
+	}
+	if err := <-runErr; err != nil {
+		t.Errorf("expect nil on orderly stop, got %v", err)
+	}
+	if atomic.LoadInt32(&hooked) != 1 {
+		t.Errorf("shutdown hook not called")
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	addr := freeAddress(t)
+	app := web.New(web.Address(addr), web.DrainTimeout(1))
+	app.HealthCheck("/healthz")
+	started := make(chan struct{})
+	app.RouteFunc("/slow", func(ctx *web.Context) {
+		close(started)
+		time.Sleep(3 * time.Second)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run(ctx) }()
+	waitReady(t, "http://"+addr+"/healthz")
+
+	go http.Get("http://" + addr + "/slow")
+	<-started
+	cancel()
+	if err := <-runErr; err == nil {
+		t.Errorf("expect drain timeout error")
+	}
+}
+
+func TestCloseDrainTimeout(t *testing.T) {
+	addr := freeAddress(t)
+	app := web.New(web.Address(addr), web.DrainTimeout(1))
+	app.HealthCheck("/healthz")
+	started, hung := make(chan struct{}), make(chan struct{})
+	defer close(hung)
+	app.RouteFunc("/hung", func(ctx *web.Context) {
+		close(started)
+		<-hung
+	})
+
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.Run(context.Background()) }()
+	waitReady(t, "http://"+addr+"/healthz")
+
+	go http.Get("http://" + addr + "/hung")
+	<-started
+	begin := time.Now()
+	if err := app.Close(); err == nil {
+		t.Errorf("expect drain timeout error")
+	}
+	if elapsed := time.Since(begin); elapsed > 3*time.Second {
+		t.Errorf("expect Close to give up after DrainTimeout, took %s", elapsed)
+	}
+	select {
+	case <-runErr:
+	case <-time.After(3 * time.Second):
+		t.Errorf("Run not returned after Close")
+	}
+}
+
+func TestRouteUnanchoredRegex(t *testing.T) {
+	app := web.New(web.DisableAccessLog())
+	app.RouteFunc("/api/.*", func(ctx *web.Context) { ctx.Text([]byte("regex")) })
+	app.RouteFunc("/users/(?P<id>[0-9]+)", func(ctx *web.Context) { ctx.Text([]byte(ctx.Param("id"))) })
+	app.RouteFunc("/robots.txt", func(ctx *web.Context) { ctx.Text([]byte("robots")) })
+
+	for path, expect := range map[string]string{"/api/items/1": "regex", "/users/42": "42", "/robots.txt": "robots"} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Body.String() != expect {
+			t.Errorf("%s: expect %q, got %d %q", path, expect, w.Code, w.Body)
+		}
+	}
+}
+
+func TestRoutePlainPath(t *testing.T) {
+	serve := func(app *web.Web, path string) string {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Body.String()
+	}
+	handler := func(name string) web.HandlerFunc {
+		return func(ctx *web.Context) { ctx.Text([]byte(name)) }
+	}
+
+	// plain paths are unanchored regexes in registration order, as before the radix tree
+	app := web.New(web.DisableAccessLog())
+	app.RouteFunc("/api/", handler("api"))
+	app.RouteFunc("/users/:id", handler("user"))
+	for path, expect := range map[string]string{"/api/": "api", "/api/items": "api", "/v1/api/": "api", "/users/1": "user"} {
+		if got := serve(app, path); got != expect {
+			t.Errorf("%s: expect %q, got %q", path, expect, got)
+		}
+	}
+
+	app = web.New(web.DisableAccessLog(), web.TreeRouting())
+	app.RouteFunc("/api/", handler("api"))
+	for path, expect := range map[string]string{"/api/": "api", "/api/items": "Not Found\n", "/v1/api/": "Not Found\n"} {
+		if got := serve(app, path); got != expect {
+			t.Errorf("TreeRouting %s: expect %q, got %q", path, expect, got)
+		}
+	}
 }
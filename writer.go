@@ -0,0 +1,138 @@
+package web
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// responseWriter wrap http.ResponseWriter to record status, bytes and time of the first byte,
+// passing through http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom.
+type responseWriter struct {
+	http.ResponseWriter
+	status    int
+	size      int64
+	headerAt  time.Time
+	hijacked  bool
+	startedAt time.Time
+}
+
+func newResponseWriter(w http.ResponseWriter, startedAt time.Time) *responseWriter {
+	return &responseWriter{ResponseWriter: w, startedAt: startedAt}
+}
+
+// Written return whether the header was sent
+func (w *responseWriter) Written() bool {
+	return w.status != 0
+}
+
+// WriteHeader record the first non-informational status
+func (w *responseWriter) WriteHeader(code int) {
+	if !w.Written() && (code >= 200 || code == http.StatusSwitchingProtocols) {
+		w.status = code
+		w.headerAt = time.Now()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush http.Flusher, no-op if the underlying writer doesn't support it
+func (w *responseWriter) Flush() {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack http.Hijacker
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("web: response writer does not support hijack")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+		if !w.Written() {
+			w.status = http.StatusSwitchingProtocols
+			w.headerAt = time.Now()
+		}
+	}
+	return conn, rw, err
+}
+
+// Push http.Pusher
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// ReadFrom io.ReaderFrom, keep sendfile of the underlying writer
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.Written() {
+		w.WriteHeader(http.StatusOK)
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, r)
+	}
+	w.size += n
+	return n, err
+}
+
+// Unwrap return the underlying writer, used by http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status return the status sent to the client, or the status set by handler if the header is not sent yet
+func (ctx *Context) Status() int {
+	if ctx.writer != nil && ctx.writer.Written() {
+		return ctx.writer.status
+	}
+	return ctx.statusCode
+}
+
+// Size return bytes of response body written
+func (ctx *Context) Size() int64 {
+	if ctx.writer == nil {
+		return 0
+	}
+	return ctx.writer.size
+}
+
+// Written return whether the response header was sent
+func (ctx *Context) Written() bool {
+	return ctx.writer != nil && ctx.writer.Written()
+}
+
+// Hijacked return whether the connection was hijacked
+func (ctx *Context) Hijacked() bool {
+	return ctx.writer != nil && ctx.writer.hijacked
+}
+
+// TTFB return the duration from the request beginning to the response header sent, 0 if not sent
+func (ctx *Context) TTFB() time.Duration {
+	if !ctx.Written() {
+		return 0
+	}
+	return ctx.writer.headerAt.Sub(ctx.writer.startedAt)
+}
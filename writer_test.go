@@ -0,0 +1,45 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/corex-io/web"
+)
+
+func TestResponseWriter(t *testing.T) {
+	var status int
+	var size int64
+	var ttfb time.Duration
+	app := web.New()
+	app.Use(func(ctx *web.Context) {
+		ctx.Next()
+		status, size, ttfb = ctx.Status(), ctx.Size(), ctx.TTFB()
+		if !ctx.IsFinish() || !ctx.Written() {
+			t.Errorf("expect finished after handler wrote")
+		}
+	})
+	app.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		if _, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			t.Errorf("expect hijack unsupported by recorder")
+		}
+		if err := w.(http.Pusher).Push("/a.js", nil); err != http.ErrNotSupported {
+			t.Errorf("expect push unsupported, got %v", err)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/created", nil))
+	if w.Code != http.StatusCreated || !w.Flushed {
+		t.Errorf("expect 201 flushed, got %d %v", w.Code, w.Flushed)
+	}
+	if status != http.StatusCreated || size != 5 || ttfb < time.Millisecond {
+		t.Errorf("unexpected record status=%d size=%d ttfb=%s", status, size, ttfb)
+	}
+}